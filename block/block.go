@@ -0,0 +1,65 @@
+// Package block detects and parses the embedded credentials this tool
+// annotates. Detection is pluggable: a Recognizer owns one artifact type
+// (Nebula certificates, X.509 certificates, SSH public keys, age recipients)
+// and the scanning loop in main tries each in turn rather than hard-coding
+// any one format.
+package block
+
+import "fmt"
+
+// errInvalidField is returned by a Block's Field method for a name not
+// listed in its own Fields().
+func errInvalidField(name string) error {
+	return fmt.Errorf("invalid field: %s", name)
+}
+
+// Recognizer detects and parses one kind of embedded credential.
+//
+// Most formats wrap a base64 body in "-----BEGIN ...-----"/"-----END
+// ...-----" markers; a Recognizer for one of those implements Begin and End
+// and leaves MatchLine always returning false. Formats with no such wrapper
+// (an SSH authorized_keys line, an age recipient) are a single, complete
+// line on their own; a Recognizer for one of those implements MatchLine and
+// leaves Begin/End always returning false.
+type Recognizer interface {
+	// Kind names this recognizer's artifact type, e.g. "nebula" or "x509".
+	// It qualifies -format entries for this kind, as in "x509.subject".
+	Kind() string
+
+	// Begin reports whether a trimmed line opens a multi-line block this
+	// recognizer parses.
+	Begin(trimText string) bool
+	// End reports whether a trimmed line closes a block opened by Begin.
+	End(trimText string) bool
+
+	// MatchLine reports whether a single trimmed line is, on its own, a
+	// complete block this recognizer can parse.
+	MatchLine(trimText string) bool
+
+	// Parse parses the raw block bytes - the full Begin..End block, or the
+	// single line MatchLine matched - into a Block.
+	Parse(raw []byte) (Block, error)
+}
+
+// Block is a single parsed credential, able to render any field its kind
+// defines.
+type Block interface {
+	// Kind names this block's artifact type, matching the Recognizer that
+	// produced it.
+	Kind() string
+
+	// Fields lists the field names this kind defines, so a bare (unqualified)
+	// -format entry can tell whether it applies to this block at all.
+	Fields() []string
+	// Field renders the named field's value.
+	Field(name string) (string, error)
+
+	// TemplateData returns this block's fields as a struct, for a -template
+	// comment body to range/index over directly rather than going through
+	// Field's string-only rendering.
+	TemplateData() any
+
+	// Failed reports whether this block should be treated as a verification
+	// failure. Kinds with no verification step always return false.
+	Failed() bool
+}
@@ -0,0 +1,101 @@
+package block
+
+import "testing"
+
+func TestSSHRecognizerMatchLineAndParse(t *testing.T) {
+	line := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGcDvrBJIvhi8AzUM217UUnXK8hIiYSMFDhv1fzxVSH6 alice@example.com"
+
+	r := SSHRecognizer{}
+	if !r.MatchLine(line) {
+		t.Fatalf("MatchLine(%q) = false, want true", line)
+	}
+
+	b, err := r.Parse([]byte(line))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, _ := b.Field("keyType"); got != "ssh-ed25519" {
+		t.Errorf("keyType = %q, want %q", got, "ssh-ed25519")
+	}
+	if got, _ := b.Field("comment"); got != "alice@example.com" {
+		t.Errorf("comment = %q, want %q", got, "alice@example.com")
+	}
+	if b.Failed() {
+		t.Errorf("Failed() = true for an SSH key")
+	}
+}
+
+func TestSSHRecognizerMatchLineRejectsNonKeyLines(t *testing.T) {
+	r := SSHRecognizer{}
+	for _, line := range []string{"", "not a key", "ssh-rsa not-base64 alice"} {
+		if r.MatchLine(line) {
+			t.Errorf("MatchLine(%q) = true, want false", line)
+		}
+	}
+}
+
+func TestAgeRecognizerMatchLineAndParse(t *testing.T) {
+	recipient := "age1qyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqyqy"
+
+	r := AgeRecognizer{}
+	if !r.MatchLine(recipient) {
+		t.Fatalf("MatchLine(%q) = false, want true", recipient)
+	}
+
+	b, err := r.Parse([]byte(recipient))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, _ := b.Field("recipient"); got != recipient {
+		t.Errorf("recipient = %q, want %q", got, recipient)
+	}
+}
+
+func TestAgeRecognizerMatchLineRejectsNonRecipients(t *testing.T) {
+	r := AgeRecognizer{}
+	for _, line := range []string{"", "age1tooshort", "not-an-age-recipient"} {
+		if r.MatchLine(line) {
+			t.Errorf("MatchLine(%q) = true, want false", line)
+		}
+	}
+}
+
+func TestX509RecognizerBeginEnd(t *testing.T) {
+	r := X509Recognizer{}
+	if !r.Begin("-----BEGIN CERTIFICATE-----") {
+		t.Error("Begin(BEGIN CERTIFICATE) = false, want true")
+	}
+	if !r.End("-----END CERTIFICATE-----") {
+		t.Error("End(END CERTIFICATE) = false, want true")
+	}
+	if r.Begin("-----BEGIN NEBULA CERTIFICATE-----") {
+		t.Error("Begin(BEGIN NEBULA CERTIFICATE) = true, want false")
+	}
+}
+
+func TestX509RecognizerParseInvalidPEM(t *testing.T) {
+	r := X509Recognizer{}
+	if _, err := r.Parse([]byte("not a certificate")); err == nil {
+		t.Error("Parse(garbage) = nil error, want an error")
+	}
+}
+
+func TestNebulaRecognizerBeginEnd(t *testing.T) {
+	r := NebulaRecognizer{}
+	for _, tc := range []struct {
+		begin, end string
+	}{
+		{"-----BEGIN NEBULA CERTIFICATE-----", "-----END NEBULA CERTIFICATE-----"},
+		{"-----BEGIN NEBULA CERTIFICATE V2-----", "-----END NEBULA CERTIFICATE V2-----"},
+	} {
+		if !r.Begin(tc.begin) {
+			t.Errorf("Begin(%q) = false, want true", tc.begin)
+		}
+		if !r.End(tc.end) {
+			t.Errorf("End(%q) = false, want true", tc.end)
+		}
+	}
+	if r.Begin("-----BEGIN CERTIFICATE-----") {
+		t.Error("Begin(BEGIN CERTIFICATE) = true, want false")
+	}
+}
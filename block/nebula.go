@@ -0,0 +1,176 @@
+package block
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slackhq/nebula/cert"
+	"github.com/wadey/nebula-cert-comment/verify"
+)
+
+// NebulaRecognizer recognizes Nebula certificates, V1 or V2 alike: the two
+// share a single field set, differing only in their BEGIN/END markers and
+// how cert.UnmarshalCertificateFromPEM happens to decode them.
+type NebulaRecognizer struct {
+	// Verifier, if set, makes the status/issuer/caFingerprint/daysUntilExpiry
+	// fields available; nil leaves them blank.
+	Verifier *verify.Verifier
+}
+
+func (NebulaRecognizer) Kind() string { return "nebula" }
+
+func (NebulaRecognizer) Begin(trimText string) bool {
+	return strings.HasPrefix(trimText, "-----BEGIN NEBULA CERTIFICATE-----") ||
+		strings.HasPrefix(trimText, "-----BEGIN NEBULA CERTIFICATE V2-----")
+}
+
+func (NebulaRecognizer) End(trimText string) bool {
+	return strings.HasPrefix(trimText, "-----END NEBULA CERTIFICATE-----") ||
+		strings.HasPrefix(trimText, "-----END NEBULA CERTIFICATE V2-----")
+}
+
+func (NebulaRecognizer) MatchLine(string) bool { return false }
+
+func (r NebulaRecognizer) Parse(raw []byte) (Block, error) {
+	c, _, err := cert.UnmarshalCertificateFromPEM(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *verify.Result
+	if r.Verifier != nil {
+		rr := r.Verifier.Verify(c)
+		res = &rr
+	}
+
+	return &nebulaBlock{cert: c, res: res}, nil
+}
+
+type nebulaBlock struct {
+	cert cert.Certificate
+	res  *verify.Result
+}
+
+func (b *nebulaBlock) Kind() string { return "nebula" }
+
+func (b *nebulaBlock) Fields() []string {
+	return []string{
+		"name", "version", "curve", "groups", "notAfter", "fingerprint",
+		"networks", "unsafeNetworks", "json",
+		"status", "issuer", "caFingerprint", "daysUntilExpiry",
+	}
+}
+
+func (b *nebulaBlock) Field(name string) (string, error) {
+	switch name {
+	case "name":
+		return b.cert.Name(), nil
+	case "version":
+		return strconv.Itoa(int(b.cert.Version())), nil
+	case "curve":
+		return b.cert.Curve().String(), nil
+	case "groups":
+		return strings.Join(b.cert.Groups(), ","), nil
+	case "notAfter":
+		return b.cert.NotAfter().UTC().Format("2006-01-02"), nil
+	case "fingerprint":
+		return b.cert.Fingerprint()
+	case "networks":
+		return strings.Join(netipPrefixesToStrings(b.cert.Networks()), ","), nil
+	case "unsafeNetworks":
+		return strings.Join(netipPrefixesToStrings(b.cert.UnsafeNetworks()), ","), nil
+	case "json":
+		j, err := json.Marshal(b.cert)
+		if err != nil {
+			return "", err
+		}
+		return string(j), nil
+	case "status":
+		if b.res == nil {
+			return "", nil
+		}
+		return string(b.res.Status), nil
+	case "issuer":
+		if b.res == nil {
+			return "", nil
+		}
+		return b.res.Issuer, nil
+	case "caFingerprint":
+		if b.res == nil {
+			return "", nil
+		}
+		return b.res.CAFingerprint, nil
+	case "daysUntilExpiry":
+		if b.res == nil {
+			return "", nil
+		}
+		return strconv.Itoa(b.res.DaysUntilExpiry), nil
+	default:
+		return "", errInvalidField(name)
+	}
+}
+
+func (b *nebulaBlock) Failed() bool {
+	return b.res != nil && b.res.Failed()
+}
+
+// NebulaTemplateData is a nebulaBlock's fields laid out for a -template
+// comment body, mirroring the names in Field/Fields but typed rather than
+// pre-rendered to strings.
+type NebulaTemplateData struct {
+	Name              string
+	Version           int
+	Curve             string
+	Groups            []string
+	Networks          []netip.Prefix
+	UnsafeNetworks    []netip.Prefix
+	NotBefore         time.Time
+	NotAfter          time.Time
+	PublicKey         string
+	IssuerFingerprint string
+	Fingerprint       string
+
+	// Status, Issuer, CAFingerprint, and DaysUntilExpiry are the zero value
+	// unless -verify was given, same as the corresponding Field entries.
+	Status          string
+	Issuer          string
+	CAFingerprint   string
+	DaysUntilExpiry int
+}
+
+func (b *nebulaBlock) TemplateData() any {
+	d := NebulaTemplateData{
+		Name:              b.cert.Name(),
+		Version:           int(b.cert.Version()),
+		Curve:             b.cert.Curve().String(),
+		Groups:            b.cert.Groups(),
+		Networks:          b.cert.Networks(),
+		UnsafeNetworks:    b.cert.UnsafeNetworks(),
+		NotBefore:         b.cert.NotBefore(),
+		NotAfter:          b.cert.NotAfter(),
+		PublicKey:         hex.EncodeToString(b.cert.PublicKey()),
+		IssuerFingerprint: b.cert.Issuer(),
+	}
+	d.Fingerprint, _ = b.cert.Fingerprint()
+
+	if b.res != nil {
+		d.Status = string(b.res.Status)
+		d.Issuer = b.res.Issuer
+		d.CAFingerprint = b.res.CAFingerprint
+		d.DaysUntilExpiry = b.res.DaysUntilExpiry
+	}
+
+	return d
+}
+
+func netipPrefixesToStrings(ns []netip.Prefix) []string {
+	ss := make([]string, len(ns))
+	for i, n := range ns {
+		ss[i] = n.String()
+	}
+	return ss
+}
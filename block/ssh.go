@@ -0,0 +1,93 @@
+package block
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshKeyTypePrefixes narrows MatchLine to lines that look like an
+// authorized_keys entry before paying for a full parse.
+var sshKeyTypePrefixes = []string{
+	"ssh-rsa ",
+	"ssh-dss ",
+	"ssh-ed25519 ",
+	"ecdsa-sha2-",
+	"sk-ssh-ed25519@openssh.com ",
+	"sk-ecdsa-sha2-nistp256@openssh.com ",
+}
+
+// SSHRecognizer recognizes OpenSSH authorized_keys lines. Unlike the PEM
+// formats, a key is a single, complete line with no BEGIN/END wrapper.
+type SSHRecognizer struct{}
+
+func (SSHRecognizer) Kind() string { return "ssh" }
+
+func (SSHRecognizer) Begin(string) bool { return false }
+func (SSHRecognizer) End(string) bool   { return false }
+
+func (SSHRecognizer) MatchLine(trimText string) bool {
+	hasKeyType := false
+	for _, p := range sshKeyTypePrefixes {
+		if strings.HasPrefix(trimText, p) {
+			hasKeyType = true
+			break
+		}
+	}
+	if !hasKeyType {
+		return false
+	}
+	_, _, _, _, err := ssh.ParseAuthorizedKey([]byte(trimText))
+	return err == nil
+}
+
+func (SSHRecognizer) Parse(raw []byte) (Block, error) {
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &sshBlock{pub: pub, comment: comment}, nil
+}
+
+type sshBlock struct {
+	pub     ssh.PublicKey
+	comment string
+}
+
+func (b *sshBlock) Kind() string { return "ssh" }
+
+func (b *sshBlock) Fields() []string {
+	return []string{"keyType", "fingerprint", "comment", "keyId"}
+}
+
+func (b *sshBlock) Field(name string) (string, error) {
+	switch name {
+	case "keyType":
+		return b.pub.Type(), nil
+	case "fingerprint":
+		return ssh.FingerprintSHA256(b.pub), nil
+	case "comment", "keyId":
+		return b.comment, nil
+	default:
+		return "", errInvalidField(name)
+	}
+}
+
+func (b *sshBlock) Failed() bool { return false }
+
+// SSHTemplateData is an sshBlock's fields laid out for a -template comment
+// body, mirroring the names in Field/Fields but typed rather than
+// pre-rendered to strings.
+type SSHTemplateData struct {
+	KeyType     string
+	Fingerprint string
+	Comment     string
+}
+
+func (b *sshBlock) TemplateData() any {
+	return SSHTemplateData{
+		KeyType:     b.pub.Type(),
+		Fingerprint: ssh.FingerprintSHA256(b.pub),
+		Comment:     b.comment,
+	}
+}
@@ -0,0 +1,110 @@
+package block
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// X509Recognizer recognizes standard PEM-encoded X.509 certificates, as
+// found alongside Nebula certificates in mixed config repos (e.g. a
+// Kubernetes client cert next to a Nebula host cert).
+type X509Recognizer struct{}
+
+func (X509Recognizer) Kind() string { return "x509" }
+
+func (X509Recognizer) Begin(trimText string) bool {
+	return strings.HasPrefix(trimText, "-----BEGIN CERTIFICATE-----")
+}
+
+func (X509Recognizer) End(trimText string) bool {
+	return strings.HasPrefix(trimText, "-----END CERTIFICATE-----")
+}
+
+func (X509Recognizer) MatchLine(string) bool { return false }
+
+func (X509Recognizer) Parse(raw []byte) (Block, error) {
+	p, _ := pem.Decode(raw)
+	if p == nil {
+		return nil, fmt.Errorf("invalid PEM certificate block")
+	}
+	c, err := x509.ParseCertificate(p.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &x509Block{cert: c}, nil
+}
+
+type x509Block struct {
+	cert *x509.Certificate
+}
+
+func (b *x509Block) Kind() string { return "x509" }
+
+func (b *x509Block) Fields() []string {
+	return []string{"subject", "issuer", "notBefore", "notAfter", "sans", "serialNumber", "fingerprint"}
+}
+
+func (b *x509Block) Field(name string) (string, error) {
+	switch name {
+	case "subject":
+		return b.cert.Subject.String(), nil
+	case "issuer":
+		return b.cert.Issuer.String(), nil
+	case "notBefore":
+		return b.cert.NotBefore.UTC().Format("2006-01-02"), nil
+	case "notAfter":
+		return b.cert.NotAfter.UTC().Format("2006-01-02"), nil
+	case "sans":
+		return strings.Join(subjectAltNames(b.cert), ","), nil
+	case "serialNumber":
+		return b.cert.SerialNumber.String(), nil
+	case "fingerprint":
+		sum := sha256.Sum256(b.cert.Raw)
+		return hex.EncodeToString(sum[:]), nil
+	default:
+		return "", errInvalidField(name)
+	}
+}
+
+func (b *x509Block) Failed() bool { return false }
+
+// X509TemplateData is an x509Block's fields laid out for a -template comment
+// body, mirroring the names in Field/Fields but typed rather than
+// pre-rendered to strings.
+type X509TemplateData struct {
+	Subject      string
+	Issuer       string
+	NotBefore    time.Time
+	NotAfter     time.Time
+	SANs         []string
+	SerialNumber string
+	Fingerprint  string
+}
+
+func (b *x509Block) TemplateData() any {
+	sum := sha256.Sum256(b.cert.Raw)
+	return X509TemplateData{
+		Subject:      b.cert.Subject.String(),
+		Issuer:       b.cert.Issuer.String(),
+		NotBefore:    b.cert.NotBefore,
+		NotAfter:     b.cert.NotAfter,
+		SANs:         subjectAltNames(b.cert),
+		SerialNumber: b.cert.SerialNumber.String(),
+		Fingerprint:  hex.EncodeToString(sum[:]),
+	}
+}
+
+func subjectAltNames(c *x509.Certificate) []string {
+	sans := make([]string, 0, len(c.DNSNames)+len(c.IPAddresses)+len(c.EmailAddresses))
+	sans = append(sans, c.DNSNames...)
+	for _, ip := range c.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, c.EmailAddresses...)
+	return sans
+}
@@ -0,0 +1,214 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamWriter incrementally emits a unified diff as lines are pushed to it,
+// without ever holding the full old or new text in memory. Unlike Diff, it
+// doesn't search for a longest common subsequence: the caller already knows,
+// line by line, whether each line is unchanged or was removed/added, so
+// StreamWriter only has to do the unified-diff hunk bookkeeping (context
+// lines, hunk headers) that Diff does once it has found its matches.
+//
+// This suits transformations, like this tool's comment regeneration, where
+// most of a large file passes through untouched and only a few localized
+// spots change: the caller can stream a file line by line and only ever
+// holds a handful of context lines in memory, rather than the whole file.
+type StreamWriter struct {
+	w                io.Writer
+	oldName, newName string
+	context          int
+
+	headerWritten bool
+	changed       bool
+
+	// pending holds the most recent (up to context) unchanged lines seen
+	// outside of a hunk, kept as candidate leading context for the next one.
+	pending []string
+
+	inHunk       bool
+	hunkOldStart int // 1-indexed
+	hunkNewStart int // 1-indexed
+	hunkOldCount int
+	hunkNewCount int
+	hunkLines    []string
+
+	// trailing counts consecutive unchanged lines seen since the last
+	// change while inHunk; once it reaches 2*context the gap is wide enough
+	// to close the hunk rather than keep merging.
+	trailing int
+
+	oldLine int
+	newLine int
+}
+
+// NewStreamWriter returns a StreamWriter that writes a unified diff (in the
+// same format as Diff) to w as lines are pushed.
+func NewStreamWriter(w io.Writer, oldName, newName string) *StreamWriter {
+	return &StreamWriter{w: w, oldName: oldName, newName: newName, context: 3}
+}
+
+// Changed reports whether any Removed/Added line has been pushed so far.
+func (s *StreamWriter) Changed() bool {
+	return s.changed
+}
+
+// Same pushes a line present, unchanged, in both the old and new text.
+func (s *StreamWriter) Same(line string) error {
+	line = withNoNewlineMarker(line)
+	s.oldLine++
+	s.newLine++
+
+	if !s.inHunk {
+		s.pending = append(s.pending, line)
+		if len(s.pending) > s.context {
+			s.pending = s.pending[1:]
+		}
+		return nil
+	}
+
+	s.hunkLines = append(s.hunkLines, " "+line)
+	s.hunkOldCount++
+	s.hunkNewCount++
+	s.trailing++
+	if s.trailing >= 2*s.context {
+		return s.closeHunk()
+	}
+	return nil
+}
+
+// Removed pushes a line present only in the old text.
+func (s *StreamWriter) Removed(line string) error {
+	line = withNoNewlineMarker(line)
+	if err := s.change(); err != nil {
+		return err
+	}
+	s.oldLine++
+	s.hunkOldCount++
+	s.hunkLines = append(s.hunkLines, "-"+line)
+	return nil
+}
+
+// Added pushes a line present only in the new text.
+func (s *StreamWriter) Added(line string) error {
+	line = withNoNewlineMarker(line)
+	if err := s.change(); err != nil {
+		return err
+	}
+	s.newLine++
+	s.hunkNewCount++
+	s.hunkLines = append(s.hunkLines, "+"+line)
+	return nil
+}
+
+// withNoNewlineMarker appends noNewlineMarker (the same marker Diff attaches,
+// see lines in diff.go) whenever line doesn't end in "\n" itself, i.e. it was
+// the last line of a file with no trailing newline.
+func withNoNewlineMarker(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + noNewlineMarker
+}
+
+func (s *StreamWriter) change() error {
+	s.changed = true
+	if !s.inHunk {
+		if err := s.openHunk(); err != nil {
+			return err
+		}
+	}
+	s.trailing = 0
+	return nil
+}
+
+func (s *StreamWriter) openHunk() error {
+	if err := s.writeHeaderOnce(); err != nil {
+		return err
+	}
+
+	leading := s.pending
+	if len(leading) > s.context {
+		leading = leading[len(leading)-s.context:]
+	}
+
+	s.inHunk = true
+	s.hunkOldStart = s.oldLine - len(leading) + 1
+	s.hunkNewStart = s.newLine - len(leading) + 1
+	s.hunkOldCount = len(leading)
+	s.hunkNewCount = len(leading)
+	s.hunkLines = s.hunkLines[:0]
+	for _, l := range leading {
+		s.hunkLines = append(s.hunkLines, " "+l)
+	}
+	s.pending = nil
+	s.trailing = 0
+	return nil
+}
+
+// closeHunk trims any trailing unchanged lines down to at most context,
+// writes the accumulated hunk, and returns to the "not in a hunk" state,
+// keeping the trimmed lines as pending leading context for the next hunk.
+func (s *StreamWriter) closeHunk() error {
+	keep := s.context
+	if s.trailing < keep {
+		keep = s.trailing
+	}
+	var pending []string
+	if drop := s.trailing - keep; drop > 0 {
+		for _, l := range s.hunkLines[len(s.hunkLines)-drop:] {
+			pending = append(pending, strings.TrimPrefix(l, " "))
+		}
+		if len(pending) > s.context {
+			pending = pending[len(pending)-s.context:]
+		}
+		s.hunkLines = s.hunkLines[:len(s.hunkLines)-drop]
+		s.hunkOldCount -= drop
+		s.hunkNewCount -= drop
+	}
+
+	oldStart := s.hunkOldStart
+	if s.hunkOldCount == 0 {
+		oldStart = 0
+	}
+	newStart := s.hunkNewStart
+	if s.hunkNewCount == 0 {
+		newStart = 0
+	}
+
+	if _, err := fmt.Fprintf(s.w, "@@ -%d,%d +%d,%d @@\n", oldStart, s.hunkOldCount, newStart, s.hunkNewCount); err != nil {
+		return err
+	}
+	for _, l := range s.hunkLines {
+		if _, err := io.WriteString(s.w, l); err != nil {
+			return err
+		}
+	}
+
+	s.inHunk = false
+	s.hunkLines = nil
+	s.pending = pending
+	s.trailing = 0
+	return nil
+}
+
+func (s *StreamWriter) writeHeaderOnce() error {
+	if s.headerWritten {
+		return nil
+	}
+	s.headerWritten = true
+	_, err := fmt.Fprintf(s.w, "diff %s %s\n--- %s\n+++ %s\n", s.oldName, s.newName, s.oldName, s.newName)
+	return err
+}
+
+// Close flushes any hunk still open because EOF arrived before enough
+// trailing context accumulated to close it on its own.
+func (s *StreamWriter) Close() error {
+	if s.inHunk {
+		return s.closeHunk()
+	}
+	return nil
+}
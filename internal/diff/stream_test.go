@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+)
+
+// runStream calls push on a new StreamWriter, closes it, and returns the
+// rendered diff.
+func runStream(t *testing.T, oldName, newName string, push func(sw *StreamWriter) error) string {
+	t.Helper()
+	var buf bytes.Buffer
+	sw := NewStreamWriter(&buf, oldName, newName)
+	if err := push(sw); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.String()
+}
+
+func TestStreamWriterNoChange(t *testing.T) {
+	var buf bytes.Buffer
+	sw := NewStreamWriter(&buf, "a", "b")
+	for _, l := range []string{"one\n", "two\n", "three\n"} {
+		if err := sw.Same(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if sw.Changed() {
+		t.Errorf("Changed() = true after only Same lines")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for an all-unchanged stream, got %q", buf.String())
+	}
+}
+
+func TestStreamWriterMatchesDiffSingleLineChange(t *testing.T) {
+	old := []byte("one\ntwo\nthree\nfour\nfive\n")
+	new := []byte("one\ntwo\nTHREE\nfour\nfive\n")
+
+	want := Diff("a", old, "b", new)
+
+	out := runStream(t, "a", "b", func(sw *StreamWriter) error {
+		for _, op := range []struct {
+			kind, line string
+		}{
+			{"same", "one\n"}, {"same", "two\n"},
+			{"removed", "three\n"}, {"added", "THREE\n"},
+			{"same", "four\n"}, {"same", "five\n"},
+		} {
+			var err error
+			switch op.kind {
+			case "same":
+				err = sw.Same(op.line)
+			case "removed":
+				err = sw.Removed(op.line)
+			case "added":
+				err = sw.Added(op.line)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if out != string(want) {
+		t.Errorf("StreamWriter output differs from Diff:\nstream:\n%s\ndiff:\n%s", out, want)
+	}
+}
+
+func TestStreamWriterNoTrailingNewline(t *testing.T) {
+	old := []byte("one\ntwo\nthree")
+	new := []byte("one\ntwo\nTHREE")
+
+	want := Diff("a", old, "b", new)
+
+	out := runStream(t, "a", "b", func(sw *StreamWriter) error {
+		if err := sw.Same("one\n"); err != nil {
+			return err
+		}
+		if err := sw.Same("two\n"); err != nil {
+			return err
+		}
+		if err := sw.Removed("three"); err != nil {
+			return err
+		}
+		return sw.Added("THREE")
+	})
+
+	if !bytes.Contains([]byte(out), []byte("\\ No newline at end of file")) {
+		t.Errorf("expected a \"\\ No newline at end of file\" marker, got:\n%s", out)
+	}
+	if out != string(want) {
+		t.Errorf("StreamWriter output differs from Diff:\nstream:\n%s\ndiff:\n%s", out, want)
+	}
+}
+
+func TestStreamWriterFarApartChangesSplitIntoTwoHunks(t *testing.T) {
+	var buf bytes.Buffer
+	sw := NewStreamWriter(&buf, "a", "b")
+
+	for _, l := range []string{"1\n", "2\n", "3\n", "4\n", "5\n", "6\n", "7\n", "8\n", "9\n", "10\n"} {
+		if err := sw.Same(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sw.Removed("11\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Added("eleven\n"); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"12\n", "13\n", "14\n", "15\n", "16\n", "17\n", "18\n", "19\n", "20\n"} {
+		if err := sw.Same(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sw.Removed("21\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Added("twentyone\n"); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"22\n", "23\n"} {
+		if err := sw.Same(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if n := bytes.Count([]byte(out), []byte("@@ ")); n != 2 {
+		t.Errorf("expected 2 hunks for changes far enough apart, got %d:\n%s", n, out)
+	}
+	if !sw.Changed() {
+		t.Errorf("Changed() = false after Removed/Added lines")
+	}
+}
@@ -3,74 +3,195 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
+	"time"
 
-	"github.com/slackhq/nebula/cert"
+	"github.com/wadey/nebula-cert-comment/block"
 	"github.com/wadey/nebula-cert-comment/internal/diff"
+	"github.com/wadey/nebula-cert-comment/report"
+	"github.com/wadey/nebula-cert-comment/verify"
 )
 
 type processor struct {
 	debug bool
 
-	commentPrefix string
-	formatters    []FormatEntry
+	commentPrefix  string
+	formatters     []FormatEntry
+	template       *template.Template
+	recognizers    []block.Recognizer
+	largeFileLimit int64
 
-	// buffer for original source file bytes
+	// collectReport, when set, makes processFile/processFileStream
+	// additionally return an Entries record for every certificate found,
+	// for -report output.
+	collectReport bool
+
+	// buffer for original source file bytes, used by processFile only:
+	// processFileStream holds the current certificate block and the diff
+	// (diffBuf), never the whole file.
 	srcBuf bytes.Buffer
 
-	// buffer for output file bytes
+	// buffer for output file bytes, used by processFile only.
 	outBuf bytes.Buffer
 
 	// buffer for trimmed certificate block bytes
 	crtBuf bytes.Buffer
 
-	// buffer for raw certificate block bytes
+	// buffer for raw certificate block bytes, used by processFile only.
 	crtRaw bytes.Buffer
+
+	// crtLines holds the raw lines of the certificate block currently
+	// being read, used by processFileStream in place of crtRaw.
+	crtLines []string
+
+	// buffer for a single rendered comment line, used by processFileStream
+	// in place of outBuf.
+	commentBuf bytes.Buffer
+
+	// buffer for the diff produced by processFileStream.
+	diffBuf bytes.Buffer
 }
 
-func comment(formatters []FormatEntry, outBuf, crtBuf *bytes.Buffer) error {
-	c, _, err := cert.UnmarshalCertificateFromPEM(crtBuf.Bytes())
-	if err != nil {
-		return err
+// errSkip is returned internally by openForProcessing to signal a file that
+// should be skipped without being treated as an error.
+var errSkip = errors.New("skip")
+
+// openForProcessing stats path and opens it, unless it's over limit bytes
+// (limit <= 0 disables the check), in which case it returns errSkip. The
+// stat happens immediately before the open, rather than relying on a
+// directory-walk-time stat, so a file that grows past limit while queued
+// behind other work is still caught.
+func openForProcessing(path string, limit int64, debugLog bool) (*os.File, error) {
+	if limit > 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > limit {
+			if debugLog {
+				fmt.Fprintf(os.Stderr, "skipping large file: %q\n", path)
+			}
+			return nil, errSkip
+		}
+	}
+	return os.Open(path)
+}
+
+// comment renders the comment line for b into outBuf, using tmpl in place of
+// formatters when tmpl is set. It reports whether b failed verification, and
+// its rendered fields, when collectFields is set; tmpl collects no fields,
+// since it has no fixed list of entries to report against.
+func comment(formatters []FormatEntry, tmpl *template.Template, outBuf *bytes.Buffer, b block.Block, collectFields bool) (bool, []report.Field, error) {
+	if tmpl != nil {
+		outBuf.WriteByte(' ')
+		if err := tmpl.Execute(outBuf, b.TemplateData()); err != nil {
+			return false, nil, err
+		}
+		outBuf.WriteRune('\n')
+		return b.Failed(), nil, nil
+	}
+
+	var fields []report.Field
+	if collectFields {
+		fields = make([]report.Field, 0, len(formatters))
 	}
 
 	for _, e := range formatters {
-		err := e.Format(c, outBuf)
+		s, applicable, err := e.String(b)
 		if err != nil {
-			return err
+			return false, nil, err
 		}
+		// The report inventory keeps every formatter for a consistent
+		// schema across certificates, applicable or not; only the comment
+		// line itself omits entries, whether because they don't apply to
+		// this kind at all or because OmitEmpty/Exclude says to.
+		if collectFields {
+			fields = append(fields, report.Field{Key: e.Key(), Value: s})
+		}
+		if !applicable || e.Skip(s) {
+			continue
+		}
+		e.WriteValue(s, outBuf)
 	}
 	outBuf.WriteRune('\n')
 
+	return b.Failed(), fields, nil
+}
+
+// parseKinds validates a -kinds flag value and returns the requested kind
+// names as a set, keyed the same way as a Recognizer's Kind().
+func parseKinds(s string) (map[string]bool, error) {
+	kinds := map[string]bool{}
+	for _, k := range strings.Split(s, ",") {
+		switch k {
+		case "nebula", "x509", "ssh", "age":
+			kinds[k] = true
+		default:
+			return nil, fmt.Errorf("invalid -kinds entry: %q", k)
+		}
+	}
+	return kinds, nil
+}
+
+// beginRecognizer returns the recognizer, if any, whose Begin marker matches
+// trimText.
+func beginRecognizer(recognizers []block.Recognizer, trimText string) block.Recognizer {
+	for _, r := range recognizers {
+		if r.Begin(trimText) {
+			return r
+		}
+	}
+	return nil
+}
+
+// lineRecognizer returns the recognizer, if any, that claims trimText as a
+// complete, self-contained block (no BEGIN/END wrapper).
+func lineRecognizer(recognizers []block.Recognizer, trimText string) block.Recognizer {
+	for _, r := range recognizers {
+		if r.MatchLine(trimText) {
+			return r
+		}
+	}
 	return nil
 }
 
-func (p *processor) processFile(path string) (bool, error) {
+func (p *processor) processFile(path string) (foundCert, verifyFailed bool, entries []report.Entry, err error) {
 	p.srcBuf.Reset()
 	p.outBuf.Reset()
 	p.crtBuf.Reset()
 	p.crtRaw.Reset()
 
-	file, err := os.Open(path)
+	file, err := openForProcessing(path, p.largeFileLimit, p.debug)
 	if err != nil {
-		return false, err
+		if err == errSkip {
+			return false, false, nil, nil
+		}
+		return false, false, nil, err
 	}
 	defer file.Close()
 
 	reader := bufio.NewReader(file)
 
 	line := 1
-	inCert := false
-	certPad := ""
-	foundCert := false
+	var active block.Recognizer
+	pad := ""
+	certIndex := 0
 
 	eof := false
 	for !eof {
@@ -79,7 +200,7 @@ func (p *processor) processFile(path string) (bool, error) {
 			if err == io.EOF {
 				eof = true
 			} else {
-				return foundCert, err
+				return foundCert, verifyFailed, entries, err
 			}
 		}
 
@@ -96,51 +217,89 @@ func (p *processor) processFile(path string) (bool, error) {
 
 		_, err = p.srcBuf.Write(bs)
 		if err != nil {
-			return foundCert, err
+			return foundCert, verifyFailed, entries, err
 		}
 
 		text := string(bs)
 		trimText := strings.TrimLeft(text, " \t")
 
+		var begin, matched block.Recognizer
+		if active == nil {
+			begin = beginRecognizer(p.recognizers, trimText)
+			if begin == nil {
+				matched = lineRecognizer(p.recognizers, trimText)
+			}
+		}
+
 		switch {
-		case strings.HasPrefix(trimText, "-----BEGIN NEBULA CERTIFICATE-----"),
-			strings.HasPrefix(trimText, "-----BEGIN NEBULA CERTIFICATE V2-----"):
-			if text[0] != '-' {
-				s := strings.SplitN(text, "-", 2)
-				certPad = s[0]
-			}
-			inCert = true
-			p.crtBuf.WriteString(strings.TrimPrefix(text, certPad))
+		case begin != nil:
+			active = begin
+			pad = text[:len(text)-len(trimText)]
+			p.crtBuf.WriteString(strings.TrimPrefix(text, pad))
 			p.crtRaw.WriteString(text)
-		case strings.HasPrefix(trimText, "-----END NEBULA CERTIFICATE-----"),
-			strings.HasPrefix(trimText, "-----END NEBULA CERTIFICATE V2-----"):
-			p.crtBuf.WriteString(strings.TrimPrefix(text, certPad))
+		case active != nil && active.End(trimText):
+			p.crtBuf.WriteString(strings.TrimPrefix(text, pad))
 			p.crtRaw.WriteString(text)
 
+			b, err := active.Parse(p.crtBuf.Bytes())
+			if err != nil {
+				return true, verifyFailed, entries, err
+			}
+
 			// Write the comment line
-			p.outBuf.WriteString(certPad)
+			p.outBuf.WriteString(pad)
 			fmt.Fprint(&p.outBuf, p.commentPrefix)
-			err = comment(p.formatters, &p.outBuf, &p.crtBuf)
+			failed, fields, err := comment(p.formatters, p.template, &p.outBuf, b, p.collectReport)
 			if err != nil {
-				return true, err
+				return true, verifyFailed, entries, err
+			}
+			if failed {
+				verifyFailed = true
+			}
+			if p.collectReport {
+				entries = append(entries, report.Entry{Path: path, Index: certIndex, Fields: fields})
+				certIndex++
 			}
 
-			// Write the raw cert block
+			// Write the raw block
 			_, err = p.crtRaw.WriteTo(&p.outBuf)
 			if err != nil {
-				return true, err
+				return true, verifyFailed, entries, err
 			}
 			p.crtBuf.Reset()
 			p.crtRaw.Reset()
 
-			certPad = ""
-			inCert = false
+			active = nil
+			pad = ""
+			foundCert = true
+		case matched != nil:
+			linePad := text[:len(text)-len(trimText)]
+			b, err := matched.Parse([]byte(strings.TrimPrefix(text, linePad)))
+			if err != nil {
+				return true, verifyFailed, entries, err
+			}
+
+			p.outBuf.WriteString(linePad)
+			fmt.Fprint(&p.outBuf, p.commentPrefix)
+			failed, fields, err := comment(p.formatters, p.template, &p.outBuf, b, p.collectReport)
+			if err != nil {
+				return true, verifyFailed, entries, err
+			}
+			if failed {
+				verifyFailed = true
+			}
+			if p.collectReport {
+				entries = append(entries, report.Entry{Path: path, Index: certIndex, Fields: fields})
+				certIndex++
+			}
+
+			p.outBuf.WriteString(text)
 			foundCert = true
 		case strings.HasPrefix(trimText, p.commentPrefix):
 			// Skip and regenerate
 		default:
-			if inCert {
-				p.crtBuf.WriteString(strings.TrimPrefix(text, certPad))
+			if active != nil {
+				p.crtBuf.WriteString(strings.TrimPrefix(text, pad))
 				p.crtRaw.WriteString(text)
 			} else {
 				p.outBuf.WriteString(text)
@@ -150,7 +309,219 @@ func (p *processor) processFile(path string) (bool, error) {
 		line += 1
 	}
 
-	return foundCert, nil
+	return foundCert, verifyFailed, entries, nil
+}
+
+// processFileStream is like processFile, but it never buffers the whole
+// file: each line is classified as unchanged, removed, or added as it is
+// read and fed straight to a diff.StreamWriter, so it holds the current
+// certificate block, the open hunk, and the diff produced so far (diffBuf)
+// rather than the whole file. It has no use for outBuf/srcBuf/crtRaw, since it
+// never reconstructs the rewritten file, only the diff against it.
+//
+// Use this instead of processFile whenever the rewritten file itself isn't
+// needed, i.e. -w wasn't given.
+func (p *processor) processFileStream(path string) (foundCert, verifyFailed, changed bool, diffOut []byte, entries []report.Entry, err error) {
+	p.crtBuf.Reset()
+	p.crtLines = p.crtLines[:0]
+	p.diffBuf.Reset()
+
+	file, err := openForProcessing(path, p.largeFileLimit, p.debug)
+	if err != nil {
+		if err == errSkip {
+			return false, false, false, nil, nil, nil
+		}
+		return false, false, false, nil, nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	sw := diff.NewStreamWriter(&p.diffBuf, fmt.Sprintf("%s.orig", path), path)
+
+	line := 1
+	var active block.Recognizer
+	pad := ""
+	certIndex := 0
+
+	// oldComment holds the most recent comment line read, if any, so it can
+	// be compared against the regenerated comment once it's known (at the
+	// matching END, or at a single-line block, below) rather than
+	// unconditionally treated as changed.
+	var oldComment string
+	hasOldComment := false
+
+	// pushComment emits the regenerated comment line newComment, reusing the
+	// stashed oldComment (if any) as a single Same when they're identical,
+	// rather than a spurious remove+add.
+	pushComment := func(newComment string) error {
+		var err error
+		if hasOldComment && oldComment == newComment {
+			err = sw.Same(newComment)
+		} else {
+			if hasOldComment {
+				err = sw.Removed(oldComment)
+			}
+			if err == nil {
+				err = sw.Added(newComment)
+			}
+		}
+		oldComment, hasOldComment = "", false
+		return err
+	}
+
+	eof := false
+	for !eof {
+		bs, err := reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				eof = true
+				if len(bs) == 0 {
+					break
+				}
+			} else {
+				return foundCert, verifyFailed, sw.Changed(), nil, entries, err
+			}
+		}
+
+		if line == 1 && bytes.IndexByte(bs, 0) != -1 {
+			if p.debug {
+				fmt.Fprintf(os.Stderr, "skipping binary file: %q\n", path)
+			}
+			return false, false, false, nil, nil, nil
+		}
+
+		text := string(bs)
+		trimText := strings.TrimLeft(text, " \t")
+
+		var begin, matched block.Recognizer
+		if active == nil {
+			begin = beginRecognizer(p.recognizers, trimText)
+			if begin == nil {
+				matched = lineRecognizer(p.recognizers, trimText)
+			}
+		}
+
+		switch {
+		case begin != nil:
+			active = begin
+			pad = text[:len(text)-len(trimText)]
+			p.crtBuf.WriteString(strings.TrimPrefix(text, pad))
+			p.crtLines = append(p.crtLines, text)
+		case active != nil && active.End(trimText):
+			p.crtBuf.WriteString(strings.TrimPrefix(text, pad))
+			p.crtLines = append(p.crtLines, text)
+
+			b, err := active.Parse(p.crtBuf.Bytes())
+			if err != nil {
+				return true, verifyFailed, sw.Changed(), nil, entries, err
+			}
+
+			p.commentBuf.Reset()
+			failed, fields, err := comment(p.formatters, p.template, &p.commentBuf, b, p.collectReport)
+			if err != nil {
+				return true, verifyFailed, sw.Changed(), nil, entries, err
+			}
+			if failed {
+				verifyFailed = true
+			}
+			if p.collectReport {
+				entries = append(entries, report.Entry{Path: path, Index: certIndex, Fields: fields})
+				certIndex++
+			}
+
+			// The new comment line goes immediately before the (unchanged)
+			// block lines that follow it, matching the order processFile
+			// writes them in: comment line, then the raw block.
+			if err := pushComment(pad + p.commentPrefix + p.commentBuf.String()); err != nil {
+				return true, verifyFailed, sw.Changed(), nil, entries, err
+			}
+
+			for _, l := range p.crtLines {
+				if err := sw.Same(l); err != nil {
+					return true, verifyFailed, sw.Changed(), nil, entries, err
+				}
+			}
+
+			p.crtBuf.Reset()
+			p.crtLines = p.crtLines[:0]
+			active = nil
+			pad = ""
+			foundCert = true
+		case matched != nil:
+			linePad := text[:len(text)-len(trimText)]
+			b, err := matched.Parse([]byte(strings.TrimPrefix(text, linePad)))
+			if err != nil {
+				return true, verifyFailed, sw.Changed(), nil, entries, err
+			}
+
+			p.commentBuf.Reset()
+			failed, fields, err := comment(p.formatters, p.template, &p.commentBuf, b, p.collectReport)
+			if err != nil {
+				return true, verifyFailed, sw.Changed(), nil, entries, err
+			}
+			if failed {
+				verifyFailed = true
+			}
+			if p.collectReport {
+				entries = append(entries, report.Entry{Path: path, Index: certIndex, Fields: fields})
+				certIndex++
+			}
+
+			if err := pushComment(linePad + p.commentPrefix + p.commentBuf.String()); err != nil {
+				return true, verifyFailed, sw.Changed(), nil, entries, err
+			}
+			if err := sw.Same(text); err != nil {
+				return true, verifyFailed, sw.Changed(), nil, entries, err
+			}
+			foundCert = true
+		case strings.HasPrefix(trimText, p.commentPrefix):
+			// Stashed rather than pushed immediately: whether this becomes
+			// Same or Removed depends on the regenerated comment, known only
+			// once the matching block is reached above. If a comment was
+			// already stashed (e.g. two in a row), it wasn't immediately
+			// followed by a block, so flush it as removed first.
+			if hasOldComment {
+				if err := sw.Removed(oldComment); err != nil {
+					return foundCert, verifyFailed, sw.Changed(), nil, entries, err
+				}
+			}
+			oldComment, hasOldComment = text, true
+		default:
+			if active != nil {
+				p.crtBuf.WriteString(strings.TrimPrefix(text, pad))
+				p.crtLines = append(p.crtLines, text)
+			} else {
+				// A stashed comment not immediately followed by a block:
+				// flush it as removed, in its original position, before this
+				// line.
+				if hasOldComment {
+					if err := sw.Removed(oldComment); err != nil {
+						return foundCert, verifyFailed, sw.Changed(), nil, entries, err
+					}
+					oldComment, hasOldComment = "", false
+				}
+				if err := sw.Same(text); err != nil {
+					return foundCert, verifyFailed, sw.Changed(), nil, entries, err
+				}
+			}
+		}
+
+		line += 1
+	}
+
+	// A comment stashed on the last line has no block after it: flush it as
+	// removed, as processFile drops it too.
+	if hasOldComment {
+		if err := sw.Removed(oldComment); err != nil {
+			return foundCert, verifyFailed, sw.Changed(), nil, entries, err
+		}
+	}
+
+	if err := sw.Close(); err != nil {
+		return foundCert, verifyFailed, sw.Changed(), nil, entries, err
+	}
+
+	return foundCert, verifyFailed, sw.Changed(), bytes.Clone(p.diffBuf.Bytes()), entries, nil
 }
 
 func write(path string, fileBuf *bytes.Buffer) error {
@@ -168,6 +539,19 @@ func write(path string, fileBuf *bytes.Buffer) error {
 	return nil
 }
 
+// pathListFlag collects repeated occurrences of a flag into a slice, e.g.
+// "-ca a.pem -ca b.pem" yields []string{"a.pem", "b.pem"}.
+type pathListFlag []string
+
+func (f *pathListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *pathListFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
 type Flags struct {
 	Diff    bool
 	Write   bool
@@ -179,6 +563,19 @@ type Flags struct {
 	LargeFileLimit int64
 	CommentPrefix  string
 	Format         string
+	Template       string
+	Workers        int
+
+	Kinds string
+
+	Verify  bool
+	CAFiles pathListFlag
+	CRLFile string
+
+	Report string
+
+	Serve        string
+	ScanInterval time.Duration
 }
 
 func parseFlags() (*Flags, []string) {
@@ -187,13 +584,25 @@ func parseFlags() (*Flags, []string) {
 	flag.BoolVar(&flags.Diff, "d", false, "display diffs")
 	flag.BoolVar(&flags.Write, "w", false, "write result to files")
 	flag.BoolVar(&flags.List, "l", false, "list files whose comments need updating")
-	flag.BoolVar(&flags.Exit, "e", false, "exit(1) if changes needed/made")
+	flag.BoolVar(&flags.Exit, "e", false, "exit(1) if changes needed/made, or if -verify found a failing certificate")
 	flag.BoolVar(&flags.Debug, "debug", false, "log files we are skipping")
 	flag.BoolVar(&flags.Version, "version", false, "print version and exit")
 
 	flag.Int64Var(&flags.LargeFileLimit, "large-file-limit", 10*1000*1000, "don't process files larger than this in bytes, Set to 0 to disable")
 	flag.StringVar(&flags.CommentPrefix, "comment", "# nebula:", "prefix for comment lines")
 	flag.StringVar(&flags.Format, "format", "name,version:!=1,groups:?,networks:?,unsafeNetworks:?,notAfter,fingerprint", "The formatters to use for the comment")
+	flag.StringVar(&flags.Template, "template", "", "Go template for the comment, in place of -format/-report; inline text, or @path/to/file.tmpl to read it from a file")
+	flag.IntVar(&flags.Workers, "j", runtime.NumCPU(), "number of files to process concurrently")
+	flag.StringVar(&flags.Kinds, "kinds", "nebula", "comma separated list of credential kinds to recognize and comment: nebula, x509, ssh, age. Defaults to nebula only, for backward compatibility with versions that didn't recognize the other kinds; pass e.g. -kinds nebula,x509,ssh,age to opt into commenting those too")
+
+	flag.BoolVar(&flags.Verify, "verify", false, "verify each certificate against the CA bundle(s) given by -ca, and make the status/issuer/caFingerprint/daysUntilExpiry formatters available")
+	flag.Var(&flags.CAFiles, "ca", "path to a CA bundle PEM file to verify against, may be repeated; required with -verify")
+	flag.StringVar(&flags.CRLFile, "crl", "", "path to a file of blocked certificate fingerprints (one per line) to treat as revoked")
+
+	flag.StringVar(&flags.Report, "report", "", "in addition to the usual -d/-l/-w output, write an aggregated inventory of every certificate found to stdout in this format: json, yaml, csv, or k8s-configmap")
+
+	flag.StringVar(&flags.Serve, "serve", "", "in place of -d/-l/-w/-report, serve a JSON certificate inventory and Prometheus metrics on this address (e.g. \":8080\"), rescanning the given paths on -scan-interval")
+	flag.DurationVar(&flags.ScanInterval, "scan-interval", 5*time.Minute, "how often -serve rescans its paths")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: nebula-cert-comment [OPTION]... [FILE]...\n\n")
@@ -205,18 +614,46 @@ If none of "-d, -l, -w" are specified, defaults to "-d".
 
 If a directory is specified for FILE, it is searched recursively. Symlinks are currently skipped.
 
-Format string is a comma separated list of formatters with optional modifiers (separated by colons)
+As well as Nebula certificates, standard PEM X.509 certificates, OpenSSH
+authorized_keys lines, and age recipients can be recognized and commented;
+see -kinds to opt into them. -kinds defaults to nebula only, since
+recognizing the other kinds is a behavior change from versions that only
+understood Nebula certificates - a file with an SSH key or X.509 cert in it
+would otherwise start getting comments injected that it didn't before.
+
+Format string is a comma separated list of formatters with optional modifiers (separated by colons).
+A bare formatter name (e.g. "notAfter") applies to every kind that defines it; prefix it with a kind
+and a dot (e.g. "x509.subject") to restrict it to one kind.
 
     Formatters:
 
-        name            --  name of the certificate
-        version         --  version of the certificate
-        curve           --  curve of the certificate
-        groups          --  comma separated list of groups defined on the certificate (omitted if empty)
-        notAfter        --  expiration timestamp in UTC of the certificate, formatted as YYYY-MM-DD
-        fingerprint     --  fingerprint of the certificate
-        networks        --  networks listed in certificate
-        unsafeNetworks  --  unsafeNetworks listed in certificate
+        nebula.name            --  name of the certificate
+        nebula.version         --  version of the certificate
+        nebula.curve           --  curve of the certificate
+        nebula.groups          --  comma separated list of groups defined on the certificate (omitted if empty)
+        nebula.notAfter        --  expiration timestamp in UTC of the certificate, formatted as YYYY-MM-DD
+        nebula.fingerprint     --  fingerprint of the certificate
+        nebula.networks        --  networks listed in certificate
+        nebula.unsafeNetworks  --  unsafeNetworks listed in certificate
+        nebula.status          --  "valid", "expired", "not-yet-valid", "revoked", or "untrusted" (requires -verify, otherwise blank)
+        nebula.issuer          --  name of the verifying CA certificate (requires -verify, otherwise blank)
+        nebula.caFingerprint   --  fingerprint of the verifying CA certificate (requires -verify, otherwise blank)
+        nebula.daysUntilExpiry --  number of days until the certificate expires, negative if already expired (requires -verify, otherwise blank)
+
+        x509.subject       --  subject of the certificate
+        x509.issuer        --  issuer of the certificate
+        x509.notBefore     --  start of the certificate's validity window, formatted as YYYY-MM-DD
+        x509.notAfter      --  expiration timestamp, formatted as YYYY-MM-DD
+        x509.sans          --  comma separated list of subject alternative names
+        x509.serialNumber  --  serial number of the certificate
+        x509.fingerprint   --  SHA-256 fingerprint of the certificate
+
+        ssh.keyType      --  key type, e.g. "ssh-ed25519"
+        ssh.fingerprint  --  SHA-256 fingerprint of the key
+        ssh.comment      --  trailing comment on the authorized_keys line
+        ssh.keyId        --  alias for ssh.comment
+
+        age.recipient  --  the recipient string itself
 
     Modifiers:
 
@@ -224,17 +661,111 @@ Format string is a comma separated list of formatters with optional modifiers (s
                            EXAMPLES:  "version:!=1", "curve:!=P256"
         ?              --  omits entry if blank
                            EXAMPLES:  "groups:?"
+
+-template takes a Go text/template (https://pkg.go.dev/text/template) in place of -format, for
+output the formatter list can't express. It replaces -format's comment-line rendering outright
+(including its own use for !=/? modifiers) and cannot be combined with -report.
+
+    The template executes against a *TemplateData struct for the block's kind - NebulaTemplateData,
+    X509TemplateData, SSHTemplateData, or AgeTemplateData, see the block package - with fields named
+    after the kind's Formatters above (e.g. NebulaTemplateData.NotAfter is a time.Time, not a string).
+
+    Helper funcs, beyond the text/template built-ins:
+
+        now                          --  current time, as time.Time
+        daysUntil <time.Time>        --  days from now until t, negative if already past
+        hasGroup <groups> <group>    --  whether group appears in groups
+        inNetwork <networks> <ip>    --  whether ip is contained in any of networks
+        join <sep> <strings>         --  strings.Join, args reordered for use at the end of a pipeline
+
+    EXAMPLE:  -template 'host={{.Name}} expires_in={{daysUntil .NotAfter}}d groups=[{{join "," .Groups}}]'
+
+-report formats:
+
+    json           --  a JSON array of {path, index, ...formatters} objects
+    yaml           --  the same records as a YAML sequence of mappings
+    csv            --  the same records as a CSV table
+    k8s-configmap  --  a ConfigMap manifest, data keyed by "path#index" (any
+                       byte not allowed in a ConfigMap key escaped as "_" and
+                       two hex digits, or a hash if the key gets too long),
+                       each value every field as unquoted "key=value" pairs
+
+-serve runs as a long-lived process instead of doing a single -d/-l/-w/-report pass: it scans
+paths every -scan-interval and serves the accumulated inventory, covering every field of every
+recognized kind regardless of -format, over HTTP:
+
+    GET /certs               --  JSON array of every certificate found, {path, index, ...fields}
+    GET /certs/{fingerprint}  --  the single matching certificate, or 404
+    GET /metrics              --  Prometheus exposition: nebula_cert_not_after_seconds{name,
+                                  fingerprint,path}, nebula_cert_expired_total, and
+                                  nebula_cert_scan_duration_seconds
 `)
 	}
 	flag.Parse()
 
-	if !flags.Diff && !flags.Write && !flags.List {
+	if flags.Serve == "" && !flags.Diff && !flags.Write && !flags.List {
 		flags.Diff = true
 	}
 
 	return flags, flag.Args()
 }
 
+// fileJob is one file queued for processing, numbered in the order it was
+// discovered by the walk so results can be resynchronized afterward.
+type fileJob struct {
+	seq  int
+	path string
+}
+
+// fileResult is the outcome of processing one fileJob.
+type fileResult struct {
+	seq  int
+	path string
+
+	found        bool
+	verifyFailed bool
+	changed      bool
+	diff         []byte
+	entries      []report.Entry
+	err          error
+}
+
+// runWorker drains jobs with its own *processor, since processor holds
+// mutable per-file buffers that can't be shared across goroutines, and sends
+// a fileResult for each job to results. Writing to disk (-w) happens here,
+// inline, since it doesn't need to stay in walk order; only the results sent
+// back for -d/-l/-report output do.
+func runWorker(jobs <-chan fileJob, results chan<- fileResult, writeFiles bool, newProcessor func() *processor) {
+	p := newProcessor()
+	for job := range jobs {
+		res := fileResult{seq: job.seq, path: job.path}
+
+		if writeFiles {
+			found, failed, entries, err := p.processFile(job.path)
+			res.found, res.verifyFailed, res.entries = found, failed, entries
+			if err != nil {
+				res.err = fmt.Errorf("process %q: %w", job.path, err)
+			} else if found {
+				res.diff = diff.Diff(fmt.Sprintf("%s.orig", job.path), p.srcBuf.Bytes(), job.path, p.outBuf.Bytes())
+				res.changed = len(res.diff) > 0
+				if res.changed {
+					if err := write(job.path, &p.outBuf); err != nil {
+						res.err = fmt.Errorf("write %q: %w", job.path, err)
+					}
+				}
+			}
+		} else {
+			found, failed, changed, diffOut, entries, err := p.processFileStream(job.path)
+			res.found, res.verifyFailed, res.changed, res.diff, res.entries = found, failed, changed, diffOut, entries
+			if err != nil {
+				res.err = fmt.Errorf("process %q: %w", job.path, err)
+			}
+		}
+
+		results <- res
+	}
+}
+
 func main() {
 	flags, paths := parseFlags()
 
@@ -257,69 +788,218 @@ func main() {
 		log.Fatal(err)
 	}
 
-	p := &processor{debug: flags.Debug, formatters: formatters, commentPrefix: flags.CommentPrefix}
+	var tmpl *template.Template
+	if flags.Template != "" {
+		if flags.Report != "" {
+			log.Fatal("-template cannot be combined with -report")
+		}
+		tmpl, err = ParseTemplateSpec(flags.Template)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	changed := false
-	for _, path := range paths {
-		err := filepath.WalkDir(path, func(path string, info fs.DirEntry, err error) error {
-			if err != nil {
-				return fmt.Errorf("walk %q: %w", path, err)
-			}
-			if info.IsDir() {
-				return nil
+	if flags.Serve != "" && (flags.Diff || flags.Write || flags.List || flags.Exit || flags.Report != "" || flags.Template != "") {
+		log.Fatal("-serve cannot be combined with -d, -l, -w, -e, -report, or -template")
+	}
+
+	kinds, err := parseKinds(flags.Kinds)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var verifier *verify.Verifier
+	if flags.Verify {
+		if !kinds["nebula"] {
+			log.Fatal("-verify only affects nebula certificates; it has no effect without \"nebula\" in -kinds")
+		}
+		if len(flags.CAFiles) == 0 {
+			log.Fatal("-verify requires at least one -ca file")
+		}
+		pool, err := verify.LoadCAPool(flags.CAFiles)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if flags.CRLFile != "" {
+			if err := verify.LoadCRL(pool, flags.CRLFile); err != nil {
+				log.Fatal(err)
 			}
-			finfo, err := info.Info()
-			if err != nil {
-				return fmt.Errorf("info %q: %w", path, err)
+		}
+		verifier = verify.NewVerifier(pool)
+	}
+
+	var reportFormat report.Format
+	if flags.Report != "" {
+		reportFormat, err = report.ParseFormat(flags.Report)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	collectReport := reportFormat != ""
+
+	workers := flags.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var recognizers []block.Recognizer
+	if kinds["nebula"] {
+		recognizers = append(recognizers, block.NebulaRecognizer{Verifier: verifier})
+	}
+	if kinds["x509"] {
+		recognizers = append(recognizers, block.X509Recognizer{})
+	}
+	if kinds["ssh"] {
+		recognizers = append(recognizers, block.SSHRecognizer{})
+	}
+	if kinds["age"] {
+		recognizers = append(recognizers, block.AgeRecognizer{})
+	}
+
+	if flags.Serve != "" {
+		newProcessor := func() *processor {
+			return &processor{
+				debug:          flags.Debug,
+				formatters:     AllFormatEntries(),
+				commentPrefix:  flags.CommentPrefix,
+				recognizers:    recognizers,
+				largeFileLimit: flags.LargeFileLimit,
+				collectReport:  true,
 			}
-			if flags.LargeFileLimit > 0 && finfo.Size() > flags.LargeFileLimit {
-				if p.debug {
-					fmt.Fprintf(os.Stderr, "skipping large file: %q\n", path)
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		if err := Serve(ctx, flags.Serve, paths, workers, flags.ScanInterval, newProcessor); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	newProcessor := func() *processor {
+		return &processor{
+			debug:          flags.Debug,
+			formatters:     formatters,
+			template:       tmpl,
+			commentPrefix:  flags.CommentPrefix,
+			recognizers:    recognizers,
+			largeFileLimit: flags.LargeFileLimit,
+			collectReport:  collectReport,
+		}
+	}
+
+	jobs := make(chan fileJob)
+	results := make(chan fileResult, workers)
+
+	// walkErr records whether any path failed to walk entirely, so the
+	// process still exits non-zero even though the error itself is only
+	// logged rather than fatal (see below); it's set from the walk
+	// goroutine and read only after that goroutine, and all workers, have
+	// finished.
+	var walkErr atomic.Bool
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runWorker(jobs, results, flags.Write, newProcessor)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		defer close(jobs)
+		seq := 0
+		for _, path := range paths {
+			err := filepath.WalkDir(path, func(path string, info fs.DirEntry, err error) error {
+				if err != nil {
+					return fmt.Errorf("walk %q: %w", path, err)
 				}
-				return nil
-			}
-			if info.Type()&fs.ModeSymlink != 0 {
-				// TODO: follow symlinks?
-				if p.debug {
-					fmt.Fprintf(os.Stderr, "skipping symlink: %q\n", path)
+				if info.IsDir() {
+					return nil
+				}
+				if info.Type()&fs.ModeSymlink != 0 {
+					// TODO: follow symlinks?
+					if flags.Debug {
+						fmt.Fprintf(os.Stderr, "skipping symlink: %q\n", path)
+					}
+					return nil
 				}
+
+				jobs <- fileJob{seq: seq, path: path}
+				seq++
 				return nil
+			})
+			if err != nil {
+				// Logged and skipped, not fatal: other workers may be
+				// concurrently mid-write on unrelated files under -w, and
+				// killing the process here would leave those truncated.
+				log.Printf("walk %q: %v", path, err)
+				walkErr.Store(true)
 			}
+		}
+	}()
 
-			found, err := p.processFile(path)
-			if err != nil {
-				return fmt.Errorf("process %q: %w", path, err)
-			}
-			if found {
-				rs := diff.Diff(fmt.Sprintf("%s.orig", path), p.srcBuf.Bytes(), path, p.outBuf.Bytes())
-				if len(rs) > 0 {
-					changed = true
-					if flags.List {
-						fmt.Println(path)
-					}
-					if flags.Diff {
-						_, err = os.Stdout.Write(rs)
-						if err != nil {
-							return fmt.Errorf("diff %q: %w", path, err)
-						}
-					}
-					if flags.Write {
-						err = write(path, &p.outBuf)
-						if err != nil {
-							return fmt.Errorf("write %q: %w", path, err)
-						}
-					}
+	// Results arrive in completion order, not walk order, since workers run
+	// concurrently; pending buffers them until they can be emitted in the
+	// order the walk discovered them, so -d/-l/-report output stays
+	// deterministic regardless of which worker finished which file first.
+	changed := false
+	verifyFailed := false
+	hadErr := false
+	var entries []report.Entry
+
+	pending := map[int]fileResult{}
+	next := 0
+
+	for res := range results {
+		pending[res.seq] = res
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if r.err != nil {
+				// Logged and skipped, not fatal: other in-flight workers may
+				// be mid-write (-w) on unrelated files, and os.Exit here
+				// would tear the process down out from under them.
+				log.Printf("%v", r.err)
+				hadErr = true
+				continue
+			}
+			if r.verifyFailed {
+				verifyFailed = true
+			}
+			entries = append(entries, r.entries...)
+			if r.found && r.changed {
+				changed = true
+				if flags.List {
+					fmt.Println(r.path)
+				}
+				if flags.Diff {
+					os.Stdout.Write(r.diff)
 				}
 			}
+		}
+	}
 
-			return nil
-		})
-		if err != nil {
+	if reportFormat != "" {
+		if err := report.Write(os.Stdout, reportFormat, entries); err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	if changed && flags.Exit {
+	if hadErr || walkErr.Load() {
+		os.Exit(1)
+	}
+
+	if (changed || verifyFailed) && flags.Exit {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,255 @@
+// Package report renders an aggregated inventory of every certificate found
+// across a scanned tree, for auditing or for mounting into cluster tooling,
+// as an alternative to the tool's usual inline comment output.
+package report
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Field is a single rendered formatter value, e.g. {Key: "name", Value: "lighthouse1"}.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Entry is the inventory record for a single certificate: the file it was
+// found in, its position within that file, and its rendered fields.
+type Entry struct {
+	Path   string
+	Index  int
+	Fields []Field
+}
+
+// MarshalJSON renders path, index, and fields in that fixed order so report
+// output stays deterministic across runs.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	buf.WriteString(`"path":`)
+	pb, err := json.Marshal(e.Path)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(pb)
+
+	fmt.Fprintf(&buf, `,"index":%d`, e.Index)
+
+	for _, f := range e.Fields {
+		buf.WriteByte(',')
+		kb, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Format identifies one of the supported report writers.
+type Format string
+
+const (
+	FormatJSON         Format = "json"
+	FormatYAML         Format = "yaml"
+	FormatCSV          Format = "csv"
+	FormatK8sConfigMap Format = "k8s-configmap"
+)
+
+// ParseFormat validates a -report flag value.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatJSON, FormatYAML, FormatCSV, FormatK8sConfigMap:
+		return Format(s), nil
+	default:
+		return "", fmt.Errorf("invalid report format: %q", s)
+	}
+}
+
+// Write renders entries in the given format to w.
+func Write(w io.Writer, format Format, entries []Entry) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, entries)
+	case FormatYAML:
+		return writeYAML(w, entries)
+	case FormatCSV:
+		return writeCSV(w, entries)
+	case FormatK8sConfigMap:
+		return writeK8sConfigMap(w, entries)
+	default:
+		return fmt.Errorf("invalid report format: %q", format)
+	}
+}
+
+func writeJSON(w io.Writer, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+func writeYAML(w io.Writer, entries []Entry) error {
+	if len(entries) == 0 {
+		_, err := io.WriteString(w, "[]\n")
+		return err
+	}
+	for _, e := range entries {
+		if _, err := fmt.Fprintf(w, "- path: %s\n  index: %d\n", yamlScalar(e.Path), e.Index); err != nil {
+			return err
+		}
+		for _, f := range e.Fields {
+			if _, err := fmt.Fprintf(w, "  %s: %s\n", f.Key, yamlScalar(f.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+
+	if len(entries) > 0 {
+		header := []string{"path", "index"}
+		for _, f := range entries[0].Fields {
+			header = append(header, f.Key)
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range entries {
+		row := []string{e.Path, strconv.Itoa(e.Index)}
+		for _, f := range e.Fields {
+			row = append(row, f.Value)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// k8sMaxKeyLength is the Kubernetes object-key length limit, shared by
+// ConfigMap data keys: https://kubernetes.io/docs/concepts/overview/working-with-objects/names/#dns-subdomain-names
+const k8sMaxKeyLength = 253
+
+// k8sKeySafeByte reports whether b is allowed to pass through a ConfigMap
+// data key unescaped. '_' is excluded even though it's itself a valid key
+// byte, since it's also the escape marker below - every other valid byte
+// means "copy me as-is", so '_' must go through escaping like everything
+// else, or a literal '_' in the path would collide with an escaped byte.
+func k8sKeySafeByte(b byte) bool {
+	return b == '-' || b == '.' ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+// k8sConfigMapKey builds a ConfigMap data key for the certificate at path,
+// index within it. ConfigMap data keys are restricted to [-._a-zA-Z0-9]+, so
+// any other byte - '/' above all, but also e.g. '#' itself - is escaped as
+// '_' followed by two lowercase hex digits, the same way a raw path would
+// otherwise make kubectl apply reject the manifest outright.
+//
+// Escaping rather than collapsing every unsafe byte to a single '_' matters:
+// that would make "a/b#0" and "a_b#0" sanitize to the identical key, and
+// kubectl apply would then silently keep only one of the two entries.
+// Escaping is one-to-one, so distinct inputs can never collide - except that
+// escaping can also make a key longer than the raw path, so a path close to
+// the 253-byte object-key limit is instead rendered as a fixed-length hash.
+// That hash is given a "__" prefix, which - unlike a single '_' - can never
+// occur in the escaped form above (every escape is "_" followed by two hex
+// digits, never another "_"), so a hashed key can never collide with an
+// escaped one either.
+func k8sConfigMapKey(path string, index int) string {
+	raw := fmt.Sprintf("%s#%d", path, index)
+
+	var buf strings.Builder
+	buf.Grow(len(raw))
+	for i := 0; i < len(raw); i++ {
+		b := raw[i]
+		if k8sKeySafeByte(b) {
+			buf.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&buf, "_%02x", b)
+	}
+
+	if buf.Len() <= k8sMaxKeyLength {
+		return buf.String()
+	}
+	return fmt.Sprintf("__long-%x", sha256.Sum256([]byte(raw)))
+}
+
+// writeK8sConfigMap renders entries as a ConfigMap manifest, keyed by
+// k8sConfigMapKey, with each value the entry's fields as space-separated
+// "key=value" pairs, like the inline comment but with every field included
+// and no value quoted.
+func writeK8sConfigMap(w io.Writer, entries []Entry) error {
+	if _, err := io.WriteString(w, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: nebula-cert-inventory\ndata:\n"); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		key := k8sConfigMapKey(e.Path, e.Index)
+
+		parts := make([]string, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			parts = append(parts, fmt.Sprintf("%s=%s", f.Key, f.Value))
+		}
+
+		if _, err := fmt.Fprintf(w, "  %s: %s\n", yamlScalar(key), yamlScalar(strings.Join(parts, " "))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// yamlScalar quotes s as a YAML double-quoted scalar whenever it isn't safe
+// to emit bare (empty, or containing characters that would otherwise change
+// how a YAML parser interprets it).
+func yamlScalar(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if !yamlSafeBare(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func yamlSafeBare(s string) bool {
+	switch s[0] {
+	case ' ', '!', '&', '*', '-', '?', '|', '>', '%', '@', '`', '"', '\'', '#', ',', '[', ']', '{', '}':
+		return false
+	}
+	if s[len(s)-1] == ' ' {
+		return false
+	}
+	return !strings.ContainsAny(s, ":\n\t")
+}
@@ -0,0 +1,133 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestK8sConfigMapKeyNoCollision(t *testing.T) {
+	// These two paths differ only in whether the separator is a '/' or the
+	// character it used to collapse to; a naive sanitizer that replaces
+	// every unsafe byte with '_' maps both to the same key.
+	a := k8sConfigMapKey("a/b", 0)
+	b := k8sConfigMapKey("a_b", 0)
+	if a == b {
+		t.Errorf("k8sConfigMapKey(%q) and k8sConfigMapKey(%q) collide: both %q", "a/b", "a_b", a)
+	}
+}
+
+func TestK8sConfigMapKeyValidChars(t *testing.T) {
+	key := k8sConfigMapKey("/etc/nebula/host#1.crt", 2)
+	for i := 0; i < len(key); i++ {
+		if !k8sKeySafeByte(key[i]) && key[i] != '_' {
+			t.Errorf("k8sConfigMapKey(...) = %q contains invalid ConfigMap key byte %q", key, key[i])
+		}
+	}
+}
+
+func TestK8sConfigMapKeyLongPathFallsBackToHash(t *testing.T) {
+	longPath := "/" + strings.Repeat("a/", 150) + "host.crt"
+	key := k8sConfigMapKey(longPath, 0)
+	if len(key) > k8sMaxKeyLength {
+		t.Fatalf("k8sConfigMapKey(...) = %d bytes, want <= %d", len(key), k8sMaxKeyLength)
+	}
+	if !strings.HasPrefix(key, "__long-") {
+		t.Errorf("k8sConfigMapKey(...) = %q, want the long-path hash fallback", key)
+	}
+
+	// Still one-to-one: a different long path must hash to a different key.
+	other := k8sConfigMapKey(longPath+"x", 0)
+	if key == other {
+		t.Errorf("two different long paths produced the same fallback key %q", key)
+	}
+}
+
+// TestK8sConfigMapKeyShortPathDoesNotCollideWithHashFallback guards against a
+// short path that happens to read as "long-<64 hex chars>" (every byte of
+// which is safe, so it passes through unescaped) colliding with the hash
+// fallback's own output format.
+func TestK8sConfigMapKeyShortPathDoesNotCollideWithHashFallback(t *testing.T) {
+	lookalike := "long-" + strings.Repeat("a", 64)
+	key := k8sConfigMapKey(lookalike, 0)
+	if strings.HasPrefix(key, "__long-") {
+		t.Errorf("k8sConfigMapKey(%q) = %q, collides with the hash fallback's key format", lookalike, key)
+	}
+}
+
+func TestWriteK8sConfigMapEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeK8sConfigMap(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "data:\n") {
+		t.Errorf("expected a data: section even with no entries, got:\n%s", buf.String())
+	}
+}
+
+func TestWriteK8sConfigMapRendersFields(t *testing.T) {
+	entries := []Entry{
+		{Path: "/etc/nebula/ca.crt", Index: 0, Fields: []Field{{Key: "name", Value: "lighthouse1"}}},
+	}
+	var buf bytes.Buffer
+	if err := writeK8sConfigMap(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	want := k8sConfigMapKey("/etc/nebula/ca.crt", 0)
+	if !strings.Contains(buf.String(), want+":") {
+		t.Errorf("expected rendered key %q in output:\n%s", want, buf.String())
+	}
+	if !strings.Contains(buf.String(), "name=lighthouse1") {
+		t.Errorf("expected field rendering in output:\n%s", buf.String())
+	}
+}
+
+func TestWriteYAMLEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeYAML(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("writeYAML(nil) = %q, want %q", buf.String(), "[]\n")
+	}
+}
+
+func TestWriteCSVHeaderFromFirstEntry(t *testing.T) {
+	entries := []Entry{
+		{Path: "a.crt", Index: 0, Fields: []Field{{Key: "name", Value: "host1"}}},
+	}
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a header and one row, got:\n%s", buf.String())
+	}
+	if lines[0] != "path,index,name" {
+		t.Errorf("header = %q, want %q", lines[0], "path,index,name")
+	}
+	if lines[1] != "a.crt,0,host1" {
+		t.Errorf("row = %q, want %q", lines[1], "a.crt,0,host1")
+	}
+}
+
+func TestYAMLScalarQuotesUnsafeValues(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantBare bool
+	}{
+		{"lighthouse1", true},
+		{"", false},
+		{"has: colon", false},
+		{"#comment", false},
+		{"trailing ", false},
+	}
+	for _, tt := range tests {
+		got := yamlScalar(tt.in)
+		isBare := got == tt.in
+		if isBare != tt.wantBare {
+			t.Errorf("yamlScalar(%q) = %q, bare = %v, want bare = %v", tt.in, got, isBare, tt.wantBare)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wadey/nebula-cert-comment/report"
+)
+
+// AllFormatEntries lists every formatter defined by every recognized kind,
+// kind-qualified. -serve uses this in place of a user-chosen -format: the
+// inventory it exposes is meant to cover every field, not a comment-line-sized
+// subset. "json" is left out, since an embedded JSON blob doesn't belong in
+// an inventory record meant to be JSON itself.
+func AllFormatEntries() []FormatEntry {
+	kindFields := []struct {
+		kind   string
+		fields []string
+	}{
+		{"nebula", []string{"name", "version", "curve", "groups", "notAfter", "fingerprint", "networks", "unsafeNetworks", "status", "issuer", "caFingerprint", "daysUntilExpiry"}},
+		{"x509", []string{"subject", "issuer", "notBefore", "notAfter", "sans", "serialNumber", "fingerprint"}},
+		{"ssh", []string{"keyType", "fingerprint", "comment", "keyId"}},
+		{"age", []string{"recipient"}},
+	}
+
+	var fes []FormatEntry
+	for _, kf := range kindFields {
+		for _, field := range kf.fields {
+			fes = append(fes, FormatEntry{Kind: kf.kind, Field: field})
+		}
+	}
+	return fes
+}
+
+// inventory is the result of one -serve scan: every certificate found, and
+// how long the scan took. It's replaced wholesale at the end of each scan,
+// rather than mutated in place, so a request being served never sees a scan
+// half-applied.
+type inventory struct {
+	entries      []report.Entry
+	scannedAt    time.Time
+	scanDuration time.Duration
+}
+
+// byFingerprint returns the entry whose "nebula.fingerprint", "x509.fingerprint",
+// or "ssh.fingerprint" field equals fingerprint, if any.
+func (inv *inventory) byFingerprint(fingerprint string) (report.Entry, bool) {
+	for _, e := range inv.entries {
+		for _, f := range e.Fields {
+			if f.Value == fingerprint && (f.Key == "nebula.fingerprint" || f.Key == "x509.fingerprint" || f.Key == "ssh.fingerprint") {
+				return e, true
+			}
+		}
+	}
+	return report.Entry{}, false
+}
+
+// fieldValue returns the value of the fields entry keyed key, if e has one.
+func fieldValue(e report.Entry, key string) (string, bool) {
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// server periodically scans paths for certificates, reusing the same
+// processor/comment pipeline as the usual comment-line output, and serves
+// the result as a JSON inventory and a Prometheus exposition over HTTP -
+// a drop-in expiry-monitoring endpoint in place of the usual diff/list/write
+// output.
+type server struct {
+	paths        []string
+	workers      int
+	scanInterval time.Duration
+	newProcessor func() *processor
+
+	mu  sync.RWMutex
+	inv inventory
+}
+
+// scan walks paths once, building a fresh inventory from every certificate
+// found. A per-file processing error is logged and that file's entries are
+// skipped, rather than aborting the whole scan: one malformed file shouldn't
+// take the endpoint down for every other certificate in the tree.
+func (s *server) scan() {
+	start := time.Now()
+
+	jobs := make(chan fileJob)
+	results := make(chan fileResult, s.workers)
+
+	var wg sync.WaitGroup
+	for range s.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runWorker(jobs, results, false, s.newProcessor)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		defer close(jobs)
+		seq := 0
+		for _, path := range s.paths {
+			err := filepath.WalkDir(path, func(path string, info fs.DirEntry, err error) error {
+				if err != nil {
+					return fmt.Errorf("walk %q: %w", path, err)
+				}
+				if info.IsDir() || info.Type()&fs.ModeSymlink != 0 {
+					return nil
+				}
+				jobs <- fileJob{seq: seq, path: path}
+				seq++
+				return nil
+			})
+			if err != nil {
+				log.Printf("scan %q: %v", path, err)
+			}
+		}
+	}()
+
+	var entries []report.Entry
+	for res := range results {
+		if res.err != nil {
+			log.Printf("scan: %v", res.err)
+			continue
+		}
+		entries = append(entries, res.entries...)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Path != entries[j].Path {
+			return entries[i].Path < entries[j].Path
+		}
+		return entries[i].Index < entries[j].Index
+	})
+
+	s.mu.Lock()
+	s.inv = inventory{entries: entries, scannedAt: time.Now(), scanDuration: time.Since(start)}
+	s.mu.Unlock()
+}
+
+// run scans paths immediately, then again every scanInterval, until ctx is
+// canceled.
+func (s *server) run(ctx context.Context) {
+	s.scan()
+
+	ticker := time.NewTicker(s.scanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.scan()
+		}
+	}
+}
+
+func (s *server) handleCerts(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	entries := s.inv.entries
+	s.mu.RUnlock()
+
+	if entries == nil {
+		entries = []report.Entry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		log.Printf("-serve: encoding /certs: %v", err)
+	}
+}
+
+func (s *server) handleCertDetail(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	e, ok := s.inv.byFingerprint(r.PathValue("fingerprint"))
+	s.mu.RUnlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		log.Printf("-serve: encoding /certs/%s: %v", r.PathValue("fingerprint"), err)
+	}
+}
+
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	inv := s.inv
+	s.mu.RUnlock()
+
+	now := time.Now()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintf(w, "# HELP nebula_cert_not_after_seconds Unix timestamp, in seconds, of the certificate's expiration.\n")
+	fmt.Fprintf(w, "# TYPE nebula_cert_not_after_seconds gauge\n")
+	expired := 0
+	for _, e := range inv.entries {
+		name, hasName := fieldValue(e, "nebula.name")
+		fingerprint, hasFingerprint := fieldValue(e, "nebula.fingerprint")
+		notAfterStr, hasNotAfter := fieldValue(e, "nebula.notAfter")
+		if !hasName || !hasFingerprint || !hasNotAfter {
+			continue
+		}
+		// nebula.notAfter is date-only (YYYY-MM-DD, UTC), the same precision
+		// used everywhere else in this project; a cert is only really
+		// expired once that whole day has passed, not from its first
+		// moment, so the expired count compares against the end of day
+		// rather than its start.
+		notAfterDay, err := time.Parse("2006-01-02", notAfterStr)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "nebula_cert_not_after_seconds{name=%q,fingerprint=%q,path=%q} %d\n", name, fingerprint, e.Path, notAfterDay.Unix())
+		if !now.Before(notAfterDay.AddDate(0, 0, 1)) {
+			expired++
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP nebula_cert_expired_total Number of discovered certificates currently expired.\n")
+	fmt.Fprintf(w, "# TYPE nebula_cert_expired_total gauge\n")
+	fmt.Fprintf(w, "nebula_cert_expired_total %d\n", expired)
+
+	fmt.Fprintf(w, "# HELP nebula_cert_scan_duration_seconds Duration of the most recent directory scan.\n")
+	fmt.Fprintf(w, "# TYPE nebula_cert_scan_duration_seconds gauge\n")
+	fmt.Fprintf(w, "nebula_cert_scan_duration_seconds %f\n", inv.scanDuration.Seconds())
+}
+
+// Serve scans paths every scanInterval and serves the result on addr (e.g.
+// ":8080") until ctx is canceled.
+func Serve(ctx context.Context, addr string, paths []string, workers int, scanInterval time.Duration, newProcessor func() *processor) error {
+	s := &server{
+		paths:        paths,
+		workers:      workers,
+		scanInterval: scanInterval,
+		newProcessor: newProcessor,
+	}
+	go s.run(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /certs", s.handleCerts)
+	mux.HandleFunc("GET /certs/{fingerprint}", s.handleCertDetail)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+
+	httpServer := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+
+	log.Printf("-serve: listening on %s, rescanning every %s", addr, scanInterval)
+	err := httpServer.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wadey/nebula-cert-comment/report"
+)
+
+func TestAllFormatEntriesOmitsJSON(t *testing.T) {
+	for _, fe := range AllFormatEntries() {
+		if fe.Field == "json" {
+			t.Errorf("AllFormatEntries() includes the json formatter, want it omitted")
+		}
+	}
+}
+
+func TestInventoryByFingerprint(t *testing.T) {
+	inv := &inventory{entries: []report.Entry{
+		{Path: "a.crt", Index: 0, Fields: []report.Field{{Key: "nebula.fingerprint", Value: "abc"}}},
+		{Path: "b.crt", Index: 0, Fields: []report.Field{{Key: "ssh.fingerprint", Value: "def"}}},
+	}}
+
+	if e, ok := inv.byFingerprint("abc"); !ok || e.Path != "a.crt" {
+		t.Errorf("byFingerprint(abc) = %+v, %v, want a.crt, true", e, ok)
+	}
+	if e, ok := inv.byFingerprint("def"); !ok || e.Path != "b.crt" {
+		t.Errorf("byFingerprint(def) = %+v, %v, want b.crt, true", e, ok)
+	}
+	if _, ok := inv.byFingerprint("missing"); ok {
+		t.Errorf("byFingerprint(missing) = true, want false")
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	e := report.Entry{Fields: []report.Field{{Key: "name", Value: "lighthouse1"}}}
+	if v, ok := fieldValue(e, "name"); !ok || v != "lighthouse1" {
+		t.Errorf("fieldValue(name) = %q, %v, want lighthouse1, true", v, ok)
+	}
+	if _, ok := fieldValue(e, "missing"); ok {
+		t.Errorf("fieldValue(missing) = true, want false")
+	}
+}
+
+func TestHandleCertsEmptyInventoryServesEmptyArray(t *testing.T) {
+	s := &server{}
+
+	req := httptest.NewRequest(http.MethodGet, "/certs", nil)
+	rec := httptest.NewRecorder()
+	s.handleCerts(rec, req)
+
+	if rec.Body.String() != "[]\n" {
+		t.Errorf("handleCerts body = %q, want %q", rec.Body.String(), "[]\n")
+	}
+}
+
+func TestHandleCertsServesEntries(t *testing.T) {
+	s := &server{inv: inventory{entries: []report.Entry{
+		{Path: "a.crt", Index: 0, Fields: []report.Field{{Key: "nebula.name", Value: "host1"}}},
+	}}}
+
+	req := httptest.NewRequest(http.MethodGet, "/certs", nil)
+	rec := httptest.NewRecorder()
+	s.handleCerts(rec, req)
+
+	var got []report.Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if len(got) != 1 || got[0].Path != "a.crt" {
+		t.Errorf("handleCerts response = %+v, want a single a.crt entry", got)
+	}
+}
+
+func TestHandleCertDetailNotFound(t *testing.T) {
+	s := &server{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /certs/{fingerprint}", s.handleCertDetail)
+
+	req := httptest.NewRequest(http.MethodGet, "/certs/nonexistent", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleMetricsReportsExpiredCount(t *testing.T) {
+	past := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	s := &server{inv: inventory{entries: []report.Entry{
+		{Path: "expired.crt", Fields: []report.Field{
+			{Key: "nebula.name", Value: "host1"},
+			{Key: "nebula.fingerprint", Value: "abc"},
+			{Key: "nebula.notAfter", Value: past},
+		}},
+	}}}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	s.handleMetrics(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "\nnebula_cert_expired_total 1\n") {
+		t.Errorf("expected nebula_cert_expired_total 1 in metrics output:\n%s", body)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ParseTemplateSpec builds the -template comment formatter from spec: either
+// a literal text/template string, or, when spec starts with "@", the
+// contents of the file named by the rest of spec.
+func ParseTemplateSpec(spec string) (*template.Template, error) {
+	text := spec
+	if path, ok := strings.CutPrefix(spec, "@"); ok {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading template %q: %w", path, err)
+		}
+		text = string(b)
+	}
+
+	return template.New("format").Funcs(templateFuncs).Parse(text)
+}
+
+// templateFuncs are the helpers available to a -template comment body,
+// alongside the usual text/template built-ins.
+var templateFuncs = template.FuncMap{
+	"now":       time.Now,
+	"daysUntil": daysUntil,
+	"hasGroup":  hasGroup,
+	"inNetwork": inNetwork,
+	"join":      join,
+}
+
+// daysUntil returns the number of days between now and t, negative if t is
+// in the past.
+func daysUntil(t time.Time) int {
+	return int(time.Until(t).Hours() / 24)
+}
+
+// hasGroup reports whether group is present in groups.
+func hasGroup(groups []string, group string) bool {
+	return slices.Contains(groups, group)
+}
+
+// inNetwork reports whether ip, parsed as a netip.Addr, is contained in any
+// of networks. It returns false, rather than an error, if ip doesn't parse,
+// so a template can use it directly in an "if" without a separate guard.
+func inNetwork(networks []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, n := range networks {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// join is strings.Join with its arguments reordered so it reads naturally
+// at the end of a pipeline, e.g. "{{.Groups | join \",\"}}".
+func join(sep string, ss []string) string {
+	return strings.Join(ss, sep)
+}
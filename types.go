@@ -2,49 +2,25 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
-	"net/netip"
 	"regexp"
-	"strconv"
+	"slices"
 	"strings"
 
-	"github.com/slackhq/nebula/cert"
-)
-
-//go:generate go tool stringer -linecomment -type=FormatType
-type FormatType int
-
-const (
-	FormatInvalid        FormatType = iota
-	FormatName                      // name
-	FormatVersion                   // version
-	FormatCurve                     // curve
-	FormatGroups                    // groups
-	FormatNotAfter                  // notAfter
-	FormatFingerprint               // fingerprint
-	FormatNetworks                  // networks
-	FormatUnsafeNetworks            // unsafeNetworks
-	FormatJSON                      // json
+	"github.com/wadey/nebula-cert-comment/block"
 )
 
 var (
 	reBasicString = regexp.MustCompile(`^[-:_a-zA-Z0-9]*$`)
 )
 
-func ParseFormatType(s string) FormatType {
-	s = strings.ToLower(s)
-	l := strings.ToLower(_FormatType_name)
-	for i := range len(_FormatType_index) - 1 {
-		if s == l[_FormatType_index[i]:_FormatType_index[i+1]] {
-			return FormatType(i)
-		}
-	}
-	return FormatType(0)
-}
-
+// FormatEntry names a single field to render in the comment line, e.g.
+// "fingerprint" or "x509.subject". Kind is empty for an unqualified entry
+// (applies to whichever kind's block defines Field, e.g. "notAfter" matches
+// both nebula and x509 blocks), or set to restrict the entry to one kind.
 type FormatEntry struct {
-	Type FormatType
+	Kind  string
+	Field string
 
 	Exclude   string
 	OmitEmpty bool
@@ -66,11 +42,16 @@ func ParseFormatEntries(entries string) ([]FormatEntry, error) {
 
 func ParseFormatEntry(entry string) (fe FormatEntry, err error) {
 	parts := strings.Split(entry, ":")
-	ft := ParseFormatType(parts[0])
-	if ft == 0 {
-		return fe, fmt.Errorf("invalid format type: %q", entry)
+
+	name := parts[0]
+	if i := strings.Index(name, "."); i >= 0 {
+		fe.Kind, fe.Field = name[:i], name[i+1:]
+	} else {
+		fe.Field = name
+	}
+	if fe.Field == "" {
+		return fe, fmt.Errorf("invalid format entry: %q", entry)
 	}
-	fe.Type = ft
 
 	if len(parts) > 1 {
 		for _, p := range parts[1:] {
@@ -87,67 +68,55 @@ func ParseFormatEntry(entry string) (fe FormatEntry, err error) {
 	return
 }
 
-func (f FormatEntry) Format(c cert.Certificate, outBuf *bytes.Buffer) error {
-	s, err := f.String(c)
-	if err != nil {
-		return err
-	}
+// Skip reports whether a rendered value s should be omitted entirely,
+// per the entry's OmitEmpty/Exclude modifiers.
+func (f FormatEntry) Skip(s string) bool {
 	if f.OmitEmpty && s == "" {
-		return nil
+		return true
 	}
 	if f.Exclude != "" && f.Exclude == s {
-		return nil
+		return true
 	}
+	return false
+}
 
-	if f.Type == FormatJSON {
+// Key is how this entry identifies itself in output: the bare field name, or
+// "kind.field" when the entry was kind-qualified.
+func (f FormatEntry) Key() string {
+	if f.Kind == "" {
+		return f.Field
+	}
+	return f.Kind + "." + f.Field
+}
+
+// WriteValue writes the rendered value s for this entry to outBuf, in the
+// "key=value" (or bare JSON) style used for the comment line.
+func (f FormatEntry) WriteValue(s string, outBuf *bytes.Buffer) {
+	if f.Field == "json" {
 		fmt.Fprintf(outBuf, " %s", s)
 	} else if f.AddQuotes(s) {
-		fmt.Fprintf(outBuf, " %s=%q", f.Type, s)
+		fmt.Fprintf(outBuf, " %s=%q", f.Key(), s)
 	} else {
-		fmt.Fprintf(outBuf, " %s=%s", f.Type, s)
+		fmt.Fprintf(outBuf, " %s=%s", f.Key(), s)
 	}
-	return nil
 }
 
-func (f FormatEntry) String(c cert.Certificate) (string, error) {
-	switch f.Type {
-	case FormatName:
-		return c.Name(), nil
-	case FormatVersion:
-		return strconv.Itoa(int(c.Version())), nil
-	case FormatCurve:
-		return c.Curve().String(), nil
-	case FormatGroups:
-		return strings.Join(c.Groups(), ","), nil
-	case FormatNotAfter:
-		return c.NotAfter().UTC().Format("2006-01-02"), nil
-	case FormatFingerprint:
-		return c.Fingerprint()
-	case FormatNetworks:
-		return strings.Join(netipPrefixesToStrings(c.Networks()), ","), nil
-	case FormatUnsafeNetworks:
-		return strings.Join(netipPrefixesToStrings(c.UnsafeNetworks()), ","), nil
-	case FormatJSON:
-		j, err := json.Marshal(c)
-		if err != nil {
-			return "", err
-		}
-		return string(j), nil
-
-	default:
-		return "", fmt.Errorf("invalid type: %s", f.Type)
+// String renders this entry against b. applicable is false if the entry is
+// qualified for a different kind, or b's kind doesn't define this field at
+// all - as opposed to the field applying but rendering blank (e.g. "status"
+// without -verify), which callers distinguish via Skip/OmitEmpty instead.
+func (f FormatEntry) String(b block.Block) (s string, applicable bool, err error) {
+	if f.Kind != "" && f.Kind != b.Kind() {
+		return "", false, nil
+	}
+	if !slices.Contains(b.Fields(), f.Field) {
+		return "", false, nil
 	}
+	s, err = b.Field(f.Field)
+	return s, true, err
 }
 
 func (f FormatEntry) AddQuotes(s string) bool {
 	// TODO make configurable?
 	return !reBasicString.MatchString(s)
 }
-
-func netipPrefixesToStrings(ns []netip.Prefix) []string {
-	ss := make([]string, len(ns))
-	for i, n := range ns {
-		ss[i] = n.String()
-	}
-	return ss
-}
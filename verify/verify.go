@@ -0,0 +1,139 @@
+// Package verify checks embedded Nebula certificates against a trusted CA
+// pool, flagging expired, not-yet-valid, revoked, and untrusted certificates
+// so that the result can be surfaced in the comment line or used to drive a
+// non-zero exit code for CI.
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/slackhq/nebula/cert"
+)
+
+// Status describes the outcome of verifying a certificate against a CA pool.
+type Status string
+
+const (
+	StatusValid       Status = "valid"
+	StatusExpired     Status = "expired"
+	StatusNotYetValid Status = "not-yet-valid"
+	StatusRevoked     Status = "revoked"
+	StatusUntrusted   Status = "untrusted"
+)
+
+// Result is the outcome of verifying a single certificate.
+type Result struct {
+	Status Status
+	Err    error
+
+	// Issuer and CAFingerprint are populated whenever a CA matching the
+	// certificate's issuer was found in the pool, even if verification
+	// ultimately failed for another reason.
+	Issuer        string
+	CAFingerprint string
+
+	DaysUntilExpiry int
+}
+
+// Failed reports whether the certificate should be treated as a
+// verification failure (anything other than StatusValid).
+func (r Result) Failed() bool {
+	return r.Status != StatusValid
+}
+
+// Verifier checks certificates against a pool of trusted CAs and an optional
+// CRL of blocked fingerprints.
+type Verifier struct {
+	pool *cert.CAPool
+	now  func() time.Time
+}
+
+// NewVerifier creates a Verifier backed by the given CA pool.
+func NewVerifier(pool *cert.CAPool) *Verifier {
+	return &Verifier{pool: pool, now: time.Now}
+}
+
+// LoadCAPool reads one or more PEM-encoded CA bundle files and combines them
+// into a single pool.
+func LoadCAPool(paths []string) (*cert.CAPool, error) {
+	pool := cert.NewCAPool()
+	for _, path := range paths {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca %q: %w", path, err)
+		}
+		for strings.TrimSpace(string(pem)) != "" {
+			pem, err = pool.AddCAFromPEM(pem)
+			if err != nil && !errors.Is(err, cert.ErrExpired) {
+				return nil, fmt.Errorf("parsing ca %q: %w", path, err)
+			}
+		}
+	}
+	return pool, nil
+}
+
+// LoadCRL reads a file containing one blocked certificate fingerprint per
+// line (blank lines and "#" comments are ignored) and blocklists them in
+// pool.
+func LoadCRL(pool *cert.CAPool, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading crl %q: %w", path, err)
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pool.BlocklistFingerprint(line)
+	}
+
+	return nil
+}
+
+// Verify checks c's validity window, signature chain, and curve against the
+// verifier's CA pool and CRL, returning a Result describing the outcome.
+func (v *Verifier) Verify(c cert.Certificate) Result {
+	now := v.now()
+	res := Result{DaysUntilExpiry: daysUntil(c.NotAfter(), now)}
+
+	signer, signerErr := v.pool.GetCAForCert(c)
+	if signerErr == nil {
+		res.Issuer = signer.Certificate.Name()
+		res.CAFingerprint = signer.Fingerprint
+	}
+
+	switch {
+	case c.NotBefore().After(now):
+		res.Status = StatusNotYetValid
+		res.Err = fmt.Errorf("%s: certificate is not yet valid", c.Name())
+	case c.Expired(now):
+		res.Status = StatusExpired
+		res.Err = fmt.Errorf("%s: %w", c.Name(), cert.ErrExpired)
+	case signerErr == nil && signer.Certificate.Curve() != c.Curve():
+		res.Status = StatusUntrusted
+		res.Err = fmt.Errorf("%s: curve %s does not match issuing ca curve %s", c.Name(), c.Curve(), signer.Certificate.Curve())
+	default:
+		if _, err := v.pool.VerifyCertificate(now, c); err != nil {
+			res.Err = err
+			if errors.Is(err, cert.ErrBlockListed) {
+				res.Status = StatusRevoked
+			} else {
+				res.Status = StatusUntrusted
+			}
+		} else {
+			res.Status = StatusValid
+		}
+	}
+
+	return res
+}
+
+func daysUntil(t, now time.Time) int {
+	return int(t.Sub(now).Hours() / 24)
+}
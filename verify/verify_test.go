@@ -0,0 +1,170 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/slackhq/nebula/cert"
+)
+
+// newTestCA returns a self-signed CA certificate and its private key.
+func newTestCA(t *testing.T, name string, notBefore, notAfter time.Time) (cert.Certificate, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbs := cert.TBSCertificate{
+		Version:   cert.Version1,
+		Name:      name,
+		IsCA:      true,
+		NotBefore: notBefore,
+		NotAfter:  notAfter,
+		PublicKey: pub,
+		Curve:     cert.Curve_CURVE25519,
+	}
+	c, err := tbs.Sign(nil, cert.Curve_CURVE25519, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, priv
+}
+
+// newTestCert returns a certificate signed by ca, valid from notBefore to
+// notAfter.
+func newTestCert(t *testing.T, ca cert.Certificate, caKey ed25519.PrivateKey, name string, notBefore, notAfter time.Time) cert.Certificate {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbs := cert.TBSCertificate{
+		Version:   cert.Version1,
+		Name:      name,
+		Networks:  []netip.Prefix{netip.MustParsePrefix("10.0.0.1/24")},
+		NotBefore: notBefore,
+		NotAfter:  notAfter,
+		PublicKey: pub,
+		Curve:     cert.Curve_CURVE25519,
+	}
+	c, err := tbs.Sign(ca, cert.Curve_CURVE25519, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func newTestPool(t *testing.T, cas ...cert.Certificate) *cert.CAPool {
+	t.Helper()
+	pool := cert.NewCAPool()
+	for _, ca := range cas {
+		if err := pool.AddCA(ca); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return pool
+}
+
+func TestVerifyValid(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ca, caKey := newTestCA(t, "test-ca", now.AddDate(-1, 0, 0), now.AddDate(1, 0, 0))
+	leaf := newTestCert(t, ca, caKey, "host1", now.AddDate(0, 0, -1), now.AddDate(0, 0, 30))
+
+	v := NewVerifier(newTestPool(t, ca))
+	v.now = func() time.Time { return now }
+
+	res := v.Verify(leaf)
+	if res.Status != StatusValid {
+		t.Errorf("Status = %q, want %q (err: %v)", res.Status, StatusValid, res.Err)
+	}
+	if res.Failed() {
+		t.Errorf("Failed() = true for a valid certificate")
+	}
+	if res.Issuer != "test-ca" {
+		t.Errorf("Issuer = %q, want %q", res.Issuer, "test-ca")
+	}
+	if res.DaysUntilExpiry != 30 {
+		t.Errorf("DaysUntilExpiry = %d, want 30", res.DaysUntilExpiry)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ca, caKey := newTestCA(t, "test-ca", now.AddDate(-1, 0, 0), now.AddDate(1, 0, 0))
+	leaf := newTestCert(t, ca, caKey, "host1", now.AddDate(0, 0, -30), now.AddDate(0, 0, -1))
+
+	v := NewVerifier(newTestPool(t, ca))
+	v.now = func() time.Time { return now }
+
+	res := v.Verify(leaf)
+	if res.Status != StatusExpired {
+		t.Errorf("Status = %q, want %q", res.Status, StatusExpired)
+	}
+	if res.Err == nil {
+		t.Error("Err = nil for an expired certificate")
+	}
+	if !res.Failed() {
+		t.Errorf("Failed() = false for an expired certificate")
+	}
+}
+
+func TestVerifyNotYetValid(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ca, caKey := newTestCA(t, "test-ca", now.AddDate(-1, 0, 0), now.AddDate(1, 0, 0))
+	leaf := newTestCert(t, ca, caKey, "host1", now.AddDate(0, 0, 1), now.AddDate(0, 0, 30))
+
+	v := NewVerifier(newTestPool(t, ca))
+	v.now = func() time.Time { return now }
+
+	res := v.Verify(leaf)
+	if res.Status != StatusNotYetValid {
+		t.Errorf("Status = %q, want %q", res.Status, StatusNotYetValid)
+	}
+	if res.Err == nil {
+		t.Error("Err = nil for a not-yet-valid certificate")
+	}
+}
+
+func TestVerifyRevoked(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ca, caKey := newTestCA(t, "test-ca", now.AddDate(-1, 0, 0), now.AddDate(1, 0, 0))
+	leaf := newTestCert(t, ca, caKey, "host1", now.AddDate(0, 0, -1), now.AddDate(0, 0, 30))
+
+	pool := newTestPool(t, ca)
+	fp, err := leaf.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.BlocklistFingerprint(fp)
+
+	v := NewVerifier(pool)
+	v.now = func() time.Time { return now }
+
+	res := v.Verify(leaf)
+	if res.Status != StatusRevoked {
+		t.Errorf("Status = %q, want %q", res.Status, StatusRevoked)
+	}
+	if !res.Failed() {
+		t.Errorf("Failed() = false for a revoked certificate")
+	}
+}
+
+func TestVerifyUntrustedNoMatchingCA(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ca, caKey := newTestCA(t, "test-ca", now.AddDate(-1, 0, 0), now.AddDate(1, 0, 0))
+	leaf := newTestCert(t, ca, caKey, "host1", now.AddDate(0, 0, -1), now.AddDate(0, 0, 30))
+
+	// An empty pool has no CA matching leaf's issuer.
+	v := NewVerifier(cert.NewCAPool())
+	v.now = func() time.Time { return now }
+
+	res := v.Verify(leaf)
+	if res.Status != StatusUntrusted {
+		t.Errorf("Status = %q, want %q", res.Status, StatusUntrusted)
+	}
+	if res.Issuer != "" || res.CAFingerprint != "" {
+		t.Errorf("Issuer/CAFingerprint populated for an untrusted cert with no matching CA: %+v", res)
+	}
+}